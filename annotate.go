@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// compileError is an error of the compile phase, at a position relative to
+// the source as given.
+type compileError struct {
+	file         string
+	line, column int
+	message      string
+}
+
+// String returns e as the compiler prints errors.
+func (e compileError) String() string {
+	if e.file != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", e.file, e.line, e.column, e.message)
+	}
+	return fmt.Sprintf("%d:%d: %s", e.line, e.column, e.message)
+}
+
+// errorFile matches the file name yaegi puts before the position of compile
+// errors in files.
+var errorFile = regexp.MustCompile(`^([^\s:]+):\d+:\d+: `)
+
+// parseGiven parses src with the given mode, files in name order, a fragment
+// wrapped as by wrapFragment. It returns the files that parsed, if only in
+// part, and their syntax errors. Positions are those of the text parsed, with
+// shift for a single source.
+func parseGiven(src source, mode parser.Mode) (fset *token.FileSet, files []*ast.File, shift posShift, errs scanner.ErrorList) {
+	fset = token.NewFileSet()
+	parse := func(name, code string) {
+		f, err := parser.ParseFile(fset, name, code, mode)
+		if f != nil {
+			files = append(files, f)
+		}
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			errs = append(errs, list...)
+		}
+	}
+	if src.files == nil {
+		code := src.code
+		if !src.isProgram() {
+			wrap := wrapFragment(code)
+			code, shift = wrap+code+fragmentEnd(code), posShift{first: len(wrap)}
+		} else {
+			shift = src.columnShift()
+		}
+		parse("", code)
+		return fset, files, shift, errs
+	}
+	names := make([]string, 0, len(src.files))
+	for name := range src.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parse(name, src.files[name])
+	}
+	return fset, files, posShift{}, errs
+}
+
+// maxCompileErrors is the number of errors compileErrors returns at most.
+const maxCompileErrors = 50
+
+// compileErrors returns the errors of the last run, which failed to compile
+// src: the syntax errors the parser finds in it, as it does not stop at the
+// first, along with the one error the interpreter stopped at, as yaegi's type
+// checking stops at the first. Only the first error of each line is kept,
+// leaving out those following from it, the interpreter's on its line, and
+// maxCompileErrors in all.
+func (r *runner) compileErrors(src source) []compileError {
+	file := ""
+	if m := errorFile.FindStringSubmatch(r.errorMessage); m != nil && src.files[m[1]] != "" {
+		file = m[1]
+	}
+	stopped := compileError{file, r.errorLine, r.errorColumn, errorPos.ReplaceAllString(r.errorMessage, "")}
+	_, _, shift, list := parseGiven(src, parser.AllErrors)
+	if len(list) == 0 {
+		return []compileError{stopped}
+	}
+	list.Sort()
+	var errs []compileError
+	if stopped.line > 0 {
+		// The parser reports other errors than yaegi's, which stops at the
+		// first, for the same syntax error when told to find all
+		errs = append(errs, stopped)
+	}
+	for i, e := range list {
+		if i > 0 && e.Pos.Filename == list[i-1].Pos.Filename && e.Pos.Line == list[i-1].Pos.Line {
+			continue
+		}
+		line, column := unshift(shift, e.Pos.Line, e.Pos.Column)
+		if stopped.line > 0 && e.Pos.Filename == stopped.file && line == stopped.line {
+			continue
+		}
+		errs = append(errs, compileError{e.Pos.Filename, line, column, e.Msg})
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].file != errs[j].file {
+			return errs[i].file < errs[j].file
+		}
+		return errs[i].line < errs[j].line
+	})
+	return errs[:min(len(errs), maxCompileErrors)]
+}
+
+// toJS returns e as a {message, line, column} object, with the file of e
+// if any.
+func (e compileError) toJS() map[string]interface{} {
+	o := map[string]interface{}{"message": e.message, "line": e.line, "column": e.column}
+	if e.file != "" {
+		o["file"] = e.file
+	}
+	return o
+}
+
+// sourceWithErrors returns the source as given, with numbered lines, each
+// followed by a marker line for any of errs found on it, a caret under the
+// column of the error and its message. Files are listed by name, only those
+// with errors.
+func sourceWithErrors(src source, errs []compileError) string {
+	files := src.files
+	if files == nil {
+		files = map[string]string{"": src.given()}
+	}
+	byFile := map[string][]compileError{}
+	for _, e := range errs {
+		byFile[e.file] = append(byFile[e.file], e)
+	}
+	names := make([]string, 0, len(byFile))
+	for name := range byFile {
+		if _, ok := files[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if name != "" {
+			fmt.Fprintf(&b, "%s:\n", name)
+		}
+		annotateLines(&b, files[name], byFile[name])
+	}
+	return b.String()
+}
+
+// annotateLines writes code to b as sourceWithErrors does, with the errors of
+// errs, all in code.
+func annotateLines(b *strings.Builder, code string, errs []compileError) {
+	lines := strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+	width := len(fmt.Sprint(len(lines)))
+	marker := func(indent string, e compileError) {
+		fmt.Fprintf(b, "%*s | %s^ %s\n", width, "", indent, e.message)
+	}
+	for i, line := range lines {
+		fmt.Fprintf(b, "%*d | %s\n", width, i+1, line)
+		for _, e := range errs {
+			// Errors at the end of the file, as with a missing closing brace,
+			// follow the last line
+			if e.line > len(lines) && i == len(lines)-1 {
+				e.line, e.column = i+1, len(line)+1
+			}
+			if e.line != i+1 {
+				continue
+			}
+			// Columns count bytes. Tabs are kept so that the caret lines up
+			// whatever their width.
+			indent := strings.Map(func(c rune) rune {
+				if c == '\t' {
+					return c
+				}
+				return ' '
+			}, line[:min(max(e.column-1, 0), len(line))])
+			marker(indent, e)
+		}
+	}
+	// Errors without a known line come last
+	for _, e := range errs {
+		if e.line < 1 {
+			marker("", e)
+		}
+	}
+}
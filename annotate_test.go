@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticsMatchError(t *testing.T) {
+	code := "import \"fmt\"\n\nfunc main() { fmt.Println(1) "
+	result := executeGo(t, code, map[string]interface{}{"allErrors": true, "sourceWithErrors": true})
+	const want = "expected '}', found 'EOF'"
+	if e := result.Get("error").String(); !strings.Contains(e, want) {
+		t.Fatalf("error = %q, want %q", e, want)
+	}
+	if d := result.Get("diagnostics"); d.Length() != 1 || d.Index(0).Get("message").String() != want {
+		t.Errorf("diagnostics[0] = %v, want %q alone", d.Index(0).Get("message"), want)
+	}
+	if annotated := result.Get("sourceWithErrors").String(); !strings.Contains(annotated, "^ "+want) {
+		t.Errorf("sourceWithErrors = %q, want it to mark %q", annotated, want)
+	}
+}
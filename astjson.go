@@ -0,0 +1,100 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"syscall/js"
+)
+
+// nodeType is the reflect type of ast.Node.
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// skippedFields are the ast.File fields duplicating nodes found elsewhere in
+// the tree, or only set by object resolution.
+var skippedFields = map[string]bool{"Imports": true, "Unresolved": true, "Scope": true, "Obj": true}
+
+// nodeJSON converts n to an object {type, field, start, end, children}, along
+// with the scalar fields of n, such as an identifier's Name or a literal's
+// Value, under their Go names. field is the name of the field of the parent
+// node holding n, and start and end are {line, column, offset} objects.
+func nodeJSON(fset *token.FileSet, n ast.Node, field string) map[string]interface{} {
+	v := reflect.ValueOf(n).Elem()
+	obj := map[string]interface{}{
+		"type":  v.Type().Name(),
+		"start": positionJSON(fset, n.Pos()),
+		"end":   positionJSON(fset, n.End()),
+	}
+	if field != "" {
+		obj["field"] = field
+	}
+
+	children := []interface{}{}
+	for i := 0; i < v.NumField(); i++ {
+		f, name := v.Field(i), v.Type().Field(i).Name
+		if skippedFields[name] {
+			continue
+		}
+		switch {
+		case f.Type() == reflect.TypeOf(token.NoPos):
+		case f.Type().Implements(nodeType):
+			if !f.IsNil() {
+				children = append(children, nodeJSON(fset, f.Interface().(ast.Node), name))
+			}
+		case f.Kind() == reflect.Slice && f.Type().Elem().Implements(nodeType):
+			for j := 0; j < f.Len(); j++ {
+				if e := f.Index(j); !e.IsNil() {
+					children = append(children, nodeJSON(fset, e.Interface().(ast.Node), name))
+				}
+			}
+		case f.Type() == reflect.TypeOf(token.ILLEGAL):
+			obj[name] = f.Interface().(token.Token).String()
+		case f.Kind() == reflect.String || f.Kind() == reflect.Bool:
+			obj[name] = f.Interface()
+		case f.Kind() == reflect.Int:
+			obj[name] = int(f.Int())
+		}
+	}
+	obj["children"] = children
+	return obj
+}
+
+// positionJSON converts pos to a {line, column, offset} object, or nil when
+// it is unknown.
+func positionJSON(fset *token.FileSet, pos token.Pos) interface{} {
+	if !pos.IsValid() {
+		return nil
+	}
+	p := fset.Position(pos)
+	return map[string]interface{}{"line": p.Line, "column": p.Column, "offset": p.Offset}
+}
+
+// astJSONWrapper parses the source file given as first argument, comments
+// included. The Promise resolves to {ast, error}: as go/parser does, a file
+// with syntax errors still gives the tree parsed around them, with error
+// listing them, and ast is null only when not even the package clause
+// parses.
+func astJSONWrapper(this js.Value, args []js.Value) interface{} {
+	return newPromise(func(resolve, reject js.Value) {
+		if len(args) == 0 || args[0].Type() != js.TypeString {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "", args[0].String(), parser.ParseComments|parser.AllErrors|parser.SkipObjectResolution)
+		result := js.Global().Get("Object").New()
+		if f != nil && f.Package.IsValid() {
+			result.Set("ast", nodeJSON(fset, f, ""))
+		} else {
+			result.Set("ast", js.Null())
+		}
+		if err != nil {
+			result.Set("error", err.Error())
+		} else {
+			result.Set("error", "")
+		}
+		resolve.Invoke(result)
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"syscall/js"
+	"time"
+)
+
+// auditRecord returns the audit result field of the last run of src: a
+// {source, sourceHash, timestamp, runId, status, executionTimeMs} object the
+// host can log as is. source, the code as given or its files by name, is left
+// out when the audit option is "hash". The timestamp, an RFC 3339 UTC one, is
+// when the run began, by the clock of the code, which is frozen with the now
+// option.
+func (r *runner) auditRecord(src source) js.Value {
+	start := r.started
+	if r.opts.now != nil {
+		start = *r.opts.now
+	}
+	audit := js.Global().Get("Object").New()
+	if r.opts.audit != "hash" {
+		if src.files == nil {
+			audit.Set("source", src.given())
+		} else {
+			files := make(map[string]interface{}, len(src.files))
+			for name, code := range src.files {
+				files[name] = code
+			}
+			audit.Set("source", files)
+		}
+	}
+	audit.Set("sourceHash", src.hash())
+	audit.Set("timestamp", start.UTC().Format(time.RFC3339Nano))
+	audit.Set("runId", r.runID)
+	audit.Set("status", r.status())
+	audit.Set("executionTimeMs", float64(r.elapsed)/float64(time.Millisecond))
+	return audit
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"syscall/js"
+)
+
+// executeBatchWrapper runs each entry of the array given as first argument
+// and resolves to the array of their results, in order. An entry is code as
+// taken by executeGoCode, or a {code, timeout} object overriding the timeout
+// for that entry. Options, as for executeGoCode, come second and apply to
+// every entry.
+//
+// Each entry gets an interpreter of its own, so none sees the globals of
+// another, and a failing entry does not stop the next ones. Cancelling the
+// Promise cancels the current entry and all that remain.
+func executeBatchWrapper(this js.Value, args []js.Value) interface{} {
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		entries := argAt(args, 0)
+		if !isArray(entries) {
+			reject.Invoke(errorObject("Invalid or missing entries argument"))
+			return
+		}
+
+		results := make([]interface{}, entries.Length())
+		for i := range results {
+			entry := entries.Index(i)
+			// Options are parsed anew so that each entry reads stdin from the
+			// start
+			opts := parseRunOptions(argAt(args, 1))
+			code := entry
+			if entry.Type() == js.TypeObject && !isArray(entry) && entry.Get("code").Truthy() {
+				code = entry.Get("code")
+				opts.timeout = parseTimeout(entry.Get("timeout"), opts.timeout)
+			}
+			code, warning := preprocess(code)
+			src, ok := parseSource(code)
+			if !ok {
+				results[i] = errorObject("Invalid or missing code argument")
+				continue
+			}
+			result := acquireRunner(opts).eval(ctx, src, opts.timeout)
+			addWarning(result, warning)
+			results[i] = result
+		}
+		resolve.Invoke(results)
+		warmSpareRunner()
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"syscall/js"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// call invokes the function named name, as declared by code evaluated
+// earlier, with args converted from the JS array given. The JS result object
+// is that of eval, with the function's return values in its results field.
+func (r *runner) call(ctx context.Context, name string, args js.Value, timeout time.Duration) (result js.Value) {
+	r.reset()
+	start := time.Now()
+	src := source{code: name}
+	r.shift = src.columnShift()
+	var out []reflect.Value
+	defer func() {
+		if p := recover(); p != nil {
+			r.elapsed = time.Since(start)
+			r.failPanic(errorKindRuntime, p, debug.Stack())
+			result = r.result(src, reflect.Value{})
+		}
+		results := make([]interface{}, len(out))
+		for i, v := range out {
+			results[i] = toJS(v)
+		}
+		result.Set("results", results)
+	}()
+
+	type callResult struct {
+		out []reflect.Value
+		err error
+	}
+	fn, in, err := r.callArgs(name, args)
+	if err != nil {
+		r.elapsed = time.Since(start)
+		r.fail(errorKindCompile, errorKindCompile, err.Error())
+		return r.result(src, reflect.Value{})
+	}
+
+	var res callResult
+	call := func() {
+		defer func() {
+			if p := recover(); p != nil {
+				res.err = interp.Panic{Value: p, Stack: debug.Stack()}
+			}
+		}()
+		res.out = fn.Call(in)
+	}
+	if err := r.execute(ctx, call, timeout); err != nil {
+		res = callResult{err: err}
+	}
+	r.elapsed = time.Since(start)
+	r.record(errorKindRuntime, res.err, timeout)
+	out = res.out
+
+	return r.result(src, reflect.Value{})
+}
+
+// callArgs looks up the function named name and converts args, a JS array,
+// to its parameter types.
+func (r *runner) callArgs(name string, args js.Value) (fn reflect.Value, in []reflect.Value, err error) {
+	fn, err = r.interp.Eval(name)
+	if err != nil {
+		return fn, nil, err
+	}
+	if fn.Kind() != reflect.Func {
+		return fn, nil, fmt.Errorf("%s is not a function", name)
+	}
+
+	n := 0
+	if !args.IsUndefined() && !args.IsNull() {
+		if !isArray(args) {
+			return fn, nil, fmt.Errorf("arguments must be an array")
+		}
+		n = args.Length()
+	}
+	t := fn.Type()
+	if n < t.NumIn() && !(t.IsVariadic() && n == t.NumIn()-1) || n > t.NumIn() && !t.IsVariadic() {
+		return fn, nil, fmt.Errorf("%s takes %d arguments, got %d", name, t.NumIn(), n)
+	}
+	for i := 0; i < n; i++ {
+		pt := t.In(min(i, t.NumIn()-1))
+		if t.IsVariadic() && i >= t.NumIn()-1 {
+			pt = pt.Elem()
+		}
+		v, err := fromJS(args.Index(i), pt)
+		if err != nil {
+			return fn, nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		in = append(in, v)
+	}
+	return fn, in, nil
+}
+
+const (
+	callPackage = "booker/call"
+	callName    = "booker_call"
+)
+
+// useCall declares, in the main package of the interpreter, the host
+// function of execute, and compiles the call to it. This is done before any
+// code is evaluated, as yaegi runs the main function of the code again with
+// every program of the main package compiled once it is declared.
+func (r *runner) useCall() {
+	r.use(interp.Exports{callPackage + "/call": {"Call": reflect.ValueOf(func() { r.calling() })}})
+	if _, err := r.interp.Eval("import " + callName + " " + strconv.Quote(callPackage)); err != nil {
+		panic(err)
+	}
+	prog, err := r.interp.Compile(callName + ".Call()")
+	if err != nil {
+		panic(err)
+	}
+	r.callProg = prog
+}
+
+// execute calls f, by way of a program calling it run by ExecuteWithContext,
+// and returns nil once it returns, unless ctx is done or timeout elapses
+// first, as await does. Interpreted functions called from the host, through
+// reflection, only stop with a run of ExecuteWithContext, as that of f then
+// does.
+func (r *runner) execute(ctx context.Context, f func(), timeout time.Duration) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r.stop = cancel
+	defer func() { r.stop = nil }()
+	r.calling = f
+	done := r.spawn(func() { r.interp.ExecuteWithContext(runCtx, r.callProg) })
+	return r.await(ctx, cancel, done, timeout)
+}
+
+// callFunctionWrapper evaluates the code given as first argument, then calls
+// the function named by the second with the array of arguments given as
+// third. Options, as for executeGoCode, come fourth. The returned Promise
+// resolves to the result of the evaluation if it failed, and to that of the
+// call otherwise.
+func callFunctionWrapper(this js.Value, args []js.Value) interface{} {
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		src, ok := parseSource(argAt(args, 0))
+		if !ok {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+		if argAt(args, 1).Type() != js.TypeString {
+			reject.Invoke(errorObject("Invalid or missing function name"))
+			return
+		}
+
+		opts := parseRunOptions(argAt(args, 3))
+		r := acquireRunner(opts)
+		result := r.eval(ctx, src, opts.timeout)
+		if r.failure == "" {
+			result = r.call(ctx, args[1].String(), argAt(args, 2), opts.timeout)
+		}
+		resolve.Invoke(result)
+		warmSpareRunner()
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"syscall/js"
+)
+
+// Cancel functions of in-flight runs, by run number. A single JS function is
+// shared by all runs, bound to the run number, so that no js.Func outlives
+// its run.
+var (
+	runsMu  sync.Mutex
+	runs    = map[int]context.CancelFunc{}
+	lastRun int
+)
+
+var cancelRunFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	runsMu.Lock()
+	cancel := runs[args[0].Int()]
+	runsMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+})
+
+// newCancellable returns the context for a new run, along with a JS function
+// cancelling it. release must be called once the run is over.
+func newCancellable() (ctx context.Context, cancelJS js.Value, release func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runsMu.Lock()
+	lastRun++
+	id := lastRun
+	runs[id] = cancel
+	runsMu.Unlock()
+
+	release = func() {
+		runsMu.Lock()
+		delete(runs, id)
+		runsMu.Unlock()
+		cancel()
+	}
+	return ctx, cancelRunFunc.Call("bind", js.Null(), id), release
+}
+
+// newCancellablePromise is like newPromise, but fn is given a context which
+// the returned Promise's cancel method cancels.
+func newCancellablePromise(fn func(ctx context.Context, resolve, reject js.Value)) js.Value {
+	ctx, cancelJS, release := newCancellable()
+	p := newPromise(func(resolve, reject js.Value) {
+		defer release()
+		fn(ctx, resolve, reject)
+	})
+	p.Set("cancel", cancelJS)
+	return p
+}
+
+// withAbortSignal returns a context cancelled along with ctx and, when signal
+// is an AbortSignal, once it aborts, already cancelled if it has. release must
+// be called once the run is over.
+func withAbortSignal(ctx context.Context, signal js.Value) (context.Context, func()) {
+	if signal.Type() != js.TypeObject || signal.Get("aborted").Type() != js.TypeBoolean {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if signal.Get("aborted").Bool() {
+		cancel()
+		return ctx, cancel
+	}
+	onAbort := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		cancel()
+		return nil
+	})
+	signal.Call("addEventListener", "abort", onAbort)
+	return ctx, func() {
+		signal.Call("removeEventListener", "abort", onAbort)
+		onAbort.Release()
+		cancel()
+	}
+}
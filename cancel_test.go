@@ -0,0 +1,73 @@
+package main
+
+import (
+	"syscall/js"
+	"testing"
+	"time"
+)
+
+// runSpinning runs code, streaming its output, and calls spinning with the
+// run's Promise once the code first writes, then returns what it resolves to
+// and how long it took to since. Compiling, which comes before, may take
+// seconds on a cold interpreter.
+func runSpinning(code string, opts map[string]interface{}, spinning func(p js.Value)) (js.Value, time.Duration) {
+	// The run only starts once the Promise is returned, so p is set by then
+	var p js.Value
+	var started time.Time
+	onChunk := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if started.IsZero() {
+			started = time.Now()
+			spinning(p)
+		}
+		return nil
+	})
+	defer onChunk.Release()
+	p = executeGoCodeStreamingWrapper(js.Undefined(), []js.Value{js.ValueOf(code), onChunk.Value, js.ValueOf(opts)}).(js.Value)
+	result := awaitValue(p)
+	return result, time.Since(started)
+}
+
+func TestCancelBusyLoop(t *testing.T) {
+	code := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"spinning\")\n\tfor {\n\t}\n}"
+	for name, cancel := range map[string]func(p js.Value){
+		"js timer": func(p js.Value) { js.Global().Call("setTimeout", p.Get("cancel"), 100) },
+		"goroutine": func(p js.Value) {
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				p.Call("cancel")
+			}()
+		},
+	} {
+		result, elapsed := runSpinning(code, map[string]interface{}{"timeout": 5000}, cancel)
+		if got := result.Get("status").String(); got != "cancelled" {
+			t.Errorf("%s: status = %q, want cancelled", name, got)
+		}
+		if elapsed > 2*time.Second {
+			t.Errorf("%s: took %v to cancel", name, elapsed)
+		}
+	}
+}
+
+func TestAbortSignal(t *testing.T) {
+	aborted := js.Global().Get("AbortController").New()
+	aborted.Call("abort")
+	result := executeGo(t, "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"ran\")\n}", map[string]interface{}{"signal": aborted.Get("signal")})
+	if got := result.Get("status").String(); got != "cancelled" {
+		t.Errorf("aborted before the call: status = %q, want cancelled", got)
+	}
+	if got := result.Get("output").String(); got != "" {
+		t.Errorf("aborted before the call: output = %q, want none", got)
+	}
+
+	controller := js.Global().Get("AbortController").New()
+	code := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"spinning\")\n\tfor {\n\t}\n}"
+	result, elapsed := runSpinning(code, map[string]interface{}{"timeout": 5000, "signal": controller.Get("signal")}, func(js.Value) {
+		js.Global().Call("setTimeout", controller.Get("abort").Call("bind", controller), 100)
+	})
+	if got := result.Get("status").String(); got != "cancelled" {
+		t.Errorf("aborted while spinning: status = %q, want cancelled", got)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("aborted while spinning: took %v to stop", elapsed)
+	}
+}
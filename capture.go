@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall/js"
+)
+
+// ansiEscape matches ANSI escape sequences: CSI sequences such as the
+// "\x1b[31m" color codes, OSC sequences, and two-character escapes.
+var ansiEscape = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// captured returns the output of o for the result object, without ANSI
+// escape sequences if the stripAnsi option is set.
+func (r *runner) captured(o *outputCapturer) string {
+	if r.opts.stripAnsi {
+		return ansiEscape.ReplaceAllString(o.String(), "")
+	}
+	return o.String()
+}
+
+// normalizeOutput returns s with \r\n and \r line endings made \n, trailing
+// whitespace trimmed from each line and, if stripFinalNewline, a final newline
+// removed.
+func normalizeOutput(s string, stripFinalNewline bool) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\f\v")
+	}
+	s = strings.Join(lines, "\n")
+	if stripFinalNewline {
+		s = strings.TrimSuffix(s, "\n")
+	}
+	return s
+}
+
+// outputCapturer captures one output stream of a runner. Its methods may be
+// called concurrently: a goroutine of a run given up on, after a timeout,
+// keeps writing while the result is read.
+type outputCapturer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	// stream, when a JS function, is also invoked with each chunk written
+	// and runID, the runId of the run writing it
+	stream js.Value
+	runID  string
+	// lines, when set, holds back the chunks streamed until a newline, so
+	// that stream is only invoked with complete lines, pending those not
+	// complete yet, until flushed
+	lines   bool
+	pending []byte
+	// name is the stream written to, as recorded in the transcript log when
+	// set
+	name string
+	log  *transcript
+	// limit is the number of bytes kept in buf; once reached, a truncation
+	// marker is written, further output is dropped and full, when set, is
+	// called
+	limit     int
+	truncated bool
+	full      func()
+}
+
+func (o *outputCapturer) Write(p []byte) (n int, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n = len(p)
+	if o.truncated {
+		return n, nil
+	}
+	if o.buf.Len()+len(p) > o.limit {
+		p = p[:max(o.limit-o.buf.Len(), 0)]
+		o.truncated = true
+	}
+
+	if o.stream.Type() == js.TypeFunction && len(p) > 0 {
+		chunk := p
+		if o.lines {
+			o.pending = append(o.pending, p...)
+			end := bytes.LastIndexByte(o.pending, '\n') + 1
+			chunk, o.pending = o.pending[:end], bytes.Clone(o.pending[end:])
+		}
+		if len(chunk) > 0 {
+			o.stream.Invoke(string(chunk), o.runID)
+		}
+	}
+	if o.log != nil && len(p) > 0 {
+		o.log.append(o.name, p)
+	}
+	o.buf.Write(p)
+	if o.truncated {
+		fmt.Fprintf(&o.buf, "\n... output truncated (limit %d bytes)\n", o.limit)
+		if o.full != nil {
+			o.full()
+		}
+	}
+	return n, nil
+}
+
+// WriteString appends s regardless of the limit, for the runner's own
+// messages.
+func (o *outputCapturer) WriteString(s string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.WriteString(s)
+}
+
+// String returns the output captured so far.
+func (o *outputCapturer) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// Bytes returns a copy of the output captured so far.
+func (o *outputCapturer) Bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return bytes.Clone(o.buf.Bytes())
+}
+
+// Len returns the number of bytes captured so far.
+func (o *outputCapturer) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Len()
+}
+
+func (o *outputCapturer) reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.Reset()
+	o.truncated = false
+	o.pending = nil
+}
+
+// flush streams the partial line held back in lines mode, if any.
+func (o *outputCapturer) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) > 0 && o.stream.Type() == js.TypeFunction {
+		o.stream.Invoke(string(o.pending), o.runID)
+	}
+	o.pending = nil
+}
+
+func (o *outputCapturer) setRunID(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.runID = id
+}
+
+// transcript records writes to several outputCapturers in the order they
+// happen.
+type transcript struct {
+	mu      sync.Mutex
+	entries []transcriptEntry
+}
+
+type transcriptEntry struct {
+	stream, text string
+}
+
+func (t *transcript) append(stream string, p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = append(t.entries, transcriptEntry{stream, string(p)})
+}
+
+func (t *transcript) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = nil
+}
+
+// toJS returns the entries as an array of {stream, text} objects.
+func (t *transcript) toJS() []interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := make([]interface{}, len(t.entries))
+	for i, e := range t.entries {
+		entry := js.Global().Get("Object").New()
+		entry.Set("stream", e.stream)
+		entry.Set("text", e.text)
+		entries[i] = entry
+	}
+	return entries
+}
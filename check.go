@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// checkGoCodeWrapper parses and type-checks the code given as first argument,
+// a source string or a map of files as for executeGoCode, without executing
+// any of it. The Promise resolves to {ok, error}.
+func checkGoCodeWrapper(this js.Value, args []js.Value) interface{} {
+	return newPromise(func(resolve, reject js.Value) {
+		src, ok := parseSource(argAt(args, 0))
+		if !ok {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+
+		_, err := acquireRunner(defaultRunOptions()).compile(src)
+		warmSpareRunner()
+
+		result := js.Global().Get("Object").New()
+		result.Set("ok", err == nil)
+		if err != nil {
+			result.Set("error", err.Error())
+		} else {
+			result.Set("error", "")
+		}
+		resolve.Invoke(result)
+	})
+}
+
+// compile runs only the parse and compile phases of src on the interpreter.
+func (r *runner) compile(src source) (prog *interp.Program, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			prog, err = nil, fmt.Errorf("compiler panic: %v", p)
+		}
+	}()
+	return r.compileSource(src)
+}
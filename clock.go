@@ -0,0 +1,19 @@
+package main
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// freezeTime makes time.Now in the interpreter always return now, with
+// time.Since and time.Until relative to it. Timers and sleeps still use the
+// real clock.
+func (r *runner) freezeTime(now time.Time) {
+	r.use(interp.Exports{"time/time": {
+		"Now":   reflect.ValueOf(func() time.Time { return now }),
+		"Since": reflect.ValueOf(func(t time.Time) time.Duration { return now.Sub(t) }),
+		"Until": reflect.ValueOf(func(t time.Time) time.Duration { return t.Sub(now) }),
+	}})
+}
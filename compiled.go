@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// compiled is a program compiled once by compileGoCode, to be executed any
+// number of times by executeCompiled. Each execution runs the package
+// initializers and main again, with fresh globals and output.
+type compiled struct {
+	// mu serializes the executions, which share the runner's output buffers
+	mu     sync.Mutex
+	runner *runner
+	src    source
+	prog   *interp.Program
+}
+
+// compiledPrograms maps the handles given out by compileGoCode to their
+// programs, until disposed of.
+var (
+	compiledMu       sync.Mutex
+	compiledPrograms = map[int]*compiled{}
+	lastHandle       int
+)
+
+// prepare compiles src without running it, returning it along with the
+// result object of the compilation.
+func (r *runner) prepare(src source) (*interp.Program, js.Value) {
+	r.reset()
+	r.shift = src.columnShift()
+	start := time.Now()
+	prog, err := r.compile(src)
+	r.elapsed = time.Since(start)
+	r.record(errorKindCompile, err, 0)
+	return prog, r.result(src, reflect.Value{})
+}
+
+// compileGoCodeWrapper compiles the code given as first argument, taking the
+// same options as executeGoCode but for stdin, args, timeout and signal, which
+// are given to each execution instead. The returned Promise resolves to a
+// result object like executeGoCode's, without output, and with a handle field
+// for executeCompiled when the code compiled.
+func compileGoCodeWrapper(this js.Value, args []js.Value) interface{} {
+	return newPromise(func(resolve, reject js.Value) {
+		src, ok := parseSource(argAt(args, 0))
+		if !ok {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+
+		opts := parseRunOptions(argAt(args, 1))
+		// Bind os.Stdin to the runner's before compiling, so that each
+		// execution may read a stdin of its own
+		opts.stdin = strings.NewReader("")
+		r := acquireRunner(opts)
+		warmSpareRunner()
+		prog, result := r.prepare(src)
+		if r.failure == "" {
+			compiledMu.Lock()
+			lastHandle++
+			compiledPrograms[lastHandle] = &compiled{runner: r, src: src, prog: prog}
+			result.Set("handle", lastHandle)
+			compiledMu.Unlock()
+		}
+		resolve.Invoke(result)
+	})
+}
+
+// lookupCompiled returns the program of the handle v, or nil if there is none.
+func lookupCompiled(v js.Value) *compiled {
+	if v.Type() != js.TypeNumber {
+		return nil
+	}
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+	return compiledPrograms[v.Int()]
+}
+
+// executeCompiledWrapper runs the program of the handle given as first
+// argument, resolving to a result object like executeGoCode's. Its optional
+// options set the stdin, args, timeout and signal of this execution only; the
+// other options are those the program was compiled with. The returned Promise
+// has a cancel method stopping the run.
+func executeCompiledWrapper(this js.Value, args []js.Value) interface{} {
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		c := lookupCompiled(argAt(args, 0))
+		if c == nil {
+			reject.Invoke(errorObject("Unknown or disposed handle"))
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		opts := parseRunOptions(argAt(args, 1))
+		r := c.runner
+		if opts.stdin == nil {
+			opts.stdin = strings.NewReader("")
+		}
+		r.setStdin(opts.stdin)
+		ctx, release := withAbortSignal(ctx, opts.signal)
+		defer release()
+		// os is missing when left out by the safeMode or disabledPackages
+		// options the program was compiled with
+		if args := r.interp.Symbols("os")["os"]["Args"]; args.IsValid() && args.CanSet() {
+			args.Set(reflect.ValueOf(append([]string{programName}, opts.args...)))
+		}
+		r.settle(r.run(ctx, c.src, c.prog, opts.timeout), resolve, reject)
+	})
+}
+
+// disposeCompiledWrapper frees the program of the handle given as first
+// argument, returning whether there was one.
+func disposeCompiledWrapper(this js.Value, args []js.Value) interface{} {
+	if argAt(args, 0).Type() != js.TypeNumber {
+		return false
+	}
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+	_, ok := compiledPrograms[args[0].Int()]
+	delete(compiledPrograms, args[0].Int())
+	return ok
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"syscall/js"
+	"testing"
+)
+
+func TestExecuteCompiledWithoutOS(t *testing.T) {
+	code := `package main
+
+import "fmt"
+
+func main() { fmt.Println("ok") }`
+	for _, opts := range []map[string]interface{}{
+		{"safeMode": true},
+		{"disabledPackages": []interface{}{"os"}},
+	} {
+		compiled := awaitValue(compileGoCodeWrapper(js.Undefined(), []js.Value{js.ValueOf(code), js.ValueOf(opts)}).(js.Value))
+		handle := compiled.Get("handle")
+		if handle.Type() != js.TypeNumber {
+			t.Fatalf("with %v, compileGoCode gave no handle: %v", opts, compiled.Get("error"))
+		}
+		args := js.ValueOf(map[string]interface{}{"args": []interface{}{"a"}})
+		result := awaitValue(executeCompiledWrapper(js.Undefined(), []js.Value{handle, args}).(js.Value))
+		if got := result.Get("output").String(); got != "ok\n" {
+			t.Errorf("with %v, output = %q, want %q", opts, got, "ok\n")
+		}
+		disposeCompiledWrapper(js.Undefined(), []js.Value{handle})
+	}
+}
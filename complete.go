@@ -0,0 +1,339 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// completion is a candidate returned by complete.
+type completion struct {
+	label, kind string
+}
+
+// Predeclared identifiers offered wherever local names are.
+var builtins = map[string][]string{
+	"func":  {"append", "cap", "clear", "close", "complex", "copy", "delete", "imag", "len", "make", "max", "min", "new", "panic", "print", "println", "real", "recover"},
+	"type":  {"any", "bool", "byte", "comparable", "complex128", "complex64", "error", "float32", "float64", "int", "int16", "int32", "int64", "int8", "rune", "string", "uint", "uint16", "uint32", "uint64", "uint8", "uintptr"},
+	"const": {"false", "iota", "nil", "true"},
+}
+
+// importPathPrefix matches the start of an import path ending at the cursor.
+var importPathPrefix = regexp.MustCompile(`(?:^|\n)\s*(?:import\s*)?(?:[\w.]+\s+)?"([\w./-]*)$`)
+
+// complete returns the completions of the identifier, or import path, that
+// ends at offset in code: members of an imported package after a selector,
+// stdlib packages inside an import declaration, and otherwise the names in
+// scope and the predeclared ones.
+func complete(code string, offset int) []completion {
+	offset = max(0, min(offset, len(code)))
+	before := code[:offset]
+
+	if m := importPathPrefix.FindStringSubmatch(before); m != nil && inImportDecl(before) {
+		var cs []completion
+		for _, p := range stdlibPackages() {
+			if strings.HasPrefix(p, m[1]) {
+				cs = append(cs, completion{p, "package"})
+			}
+		}
+		return cs
+	}
+
+	start := offset
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(code[:start])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		start -= size
+	}
+	partial := code[start:offset]
+
+	if start > 0 && code[start-1] == '.' {
+		end := start - 1
+		qual := end
+		for qual > 0 {
+			r, size := utf8.DecodeLastRuneInString(code[:qual])
+			if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			qual -= size
+		}
+		return filter(packageMembers(code, code[qual:end]), partial)
+	}
+	return filter(scopeNames(code, start), partial)
+}
+
+// inImportDecl reports whether the end of before lies in an import
+// declaration, either on an import line or in an open import block.
+func inImportDecl(before string) bool {
+	line := before[strings.LastIndex(before, "\n")+1:]
+	if strings.HasPrefix(strings.TrimSpace(line), "import") {
+		return true
+	}
+	block := strings.LastIndex(before, "import (")
+	return block >= 0 && !strings.Contains(before[block:], ")")
+}
+
+// filter keeps the completions starting with partial, sorted and without
+// duplicates.
+func filter(cs []completion, partial string) []completion {
+	seen := map[string]bool{}
+	var kept []completion
+	for _, c := range cs {
+		if strings.HasPrefix(c.label, partial) && !seen[c.label] {
+			seen[c.label] = true
+			kept = append(kept, c)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].label < kept[j].label })
+	return kept
+}
+
+// packageMembers returns the exported members of the package imported by code
+// under the local name name, if it is one the interpreter provides.
+func packageMembers(code, name string) []completion {
+	_, imports, err := parseImports(code)
+	if err != nil {
+		return nil
+	}
+	symbols := map[string]reflect.Value(nil)
+	for _, spec := range imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		key := packageKey(importPath)
+		local := path.Base(key)
+		if spec.Name != nil {
+			local = spec.Name.Name
+		}
+		if local == name {
+			symbols = packageSymbols(key)
+			break
+		}
+	}
+
+	var cs []completion
+	for member, v := range symbols {
+		// Names starting with _ are interface wrappers
+		if strings.HasPrefix(member, "_") {
+			continue
+		}
+		cs = append(cs, completion{member, symbolKind(v)})
+	}
+	return cs
+}
+
+// packageKey returns the key of the package at importPath in the symbol
+// tables, of the form "import/path/name", or "" if there is none, as for
+// yaegi's own packages.
+func packageKey(importPath string) string {
+	if isYaegiPackage(importPath) {
+		return ""
+	}
+	hostSymbolsMu.Lock()
+	defer hostSymbolsMu.Unlock()
+	for _, exports := range []map[string]map[string]reflect.Value{hostSymbols, stdlib.Symbols} {
+		for key := range exports {
+			if path.Dir(key) == importPath {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// packageSymbols returns the symbols under key, registered ones first.
+func packageSymbols(key string) map[string]reflect.Value {
+	hostSymbolsMu.Lock()
+	defer hostSymbolsMu.Unlock()
+	if symbols, ok := hostSymbols[key]; ok {
+		return symbols
+	}
+	return stdlib.Symbols[key]
+}
+
+var constantType = reflect.TypeOf((*constant.Value)(nil)).Elem()
+
+// symbolKind tells what a symbol exported to the interpreter is, following
+// the conventions of the symbol tables: variables are addressable values,
+// types are nil pointers to them, and untyped constants constant.Values.
+func symbolKind(v reflect.Value) string {
+	switch {
+	case v.CanAddr():
+		return "var"
+	case v.Kind() == reflect.Func:
+		return "func"
+	case v.Type().Implements(constantType):
+		return "const"
+	case v.Kind() == reflect.Ptr && v.IsNil():
+		return "type"
+	}
+	return "const"
+}
+
+// scopeNames returns the names visible at offset in code: package-level
+// declarations and imports, the parameters of the enclosing functions, and
+// the local declarations preceding offset in the enclosing blocks.
+func scopeNames(code string, offset int) []completion {
+	var cs []completion
+	for kind, names := range builtins {
+		for _, name := range names {
+			cs = append(cs, completion{name, kind})
+		}
+	}
+
+	prefix := wrapFragment(code)
+	fset := token.NewFileSet()
+	// A partial file still holds what parsed, which is enough here
+	f, _ := parser.ParseFile(fset, "", prefix+code+fragmentEnd(code), parser.SkipObjectResolution)
+	if f == nil {
+		return cs
+	}
+	pos := fset.File(f.Pos()).Pos(len(prefix) + offset)
+
+	for _, spec := range f.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(importPath)
+		if key := packageKey(importPath); key != "" {
+			name = path.Base(key)
+		}
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		cs = append(cs, completion{name, "package"})
+	}
+
+	add := func(ids []*ast.Ident, kind string) {
+		for _, id := range ids {
+			if id.Name != "_" {
+				cs = append(cs, completion{id.Name, kind})
+			}
+		}
+	}
+	addFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			add(field.Names, "var")
+		}
+	}
+	addSpecs := func(decl *ast.GenDecl) {
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				add([]*ast.Ident{spec.Name}, "type")
+			case *ast.ValueSpec:
+				add(spec.Names, decl.Tok.String())
+			}
+		}
+	}
+
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil && !isFragmentMain(decl) {
+				add([]*ast.Ident{decl.Name}, "func")
+			}
+		case *ast.GenDecl:
+			addSpecs(decl)
+		}
+	}
+
+	// Declarations in the blocks enclosing the cursor are in scope once
+	// they are complete
+	addLocals := func(stmts ...ast.Stmt) {
+		for _, stmt := range stmts {
+			if stmt == nil || stmt.End() > pos {
+				continue
+			}
+			switch stmt := stmt.(type) {
+			case *ast.AssignStmt:
+				if stmt.Tok != token.DEFINE {
+					continue
+				}
+				for _, lhs := range stmt.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						add([]*ast.Ident{id}, "var")
+					}
+				}
+			case *ast.DeclStmt:
+				if decl, ok := stmt.Decl.(*ast.GenDecl); ok {
+					addSpecs(decl)
+				}
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || n.Pos() > pos || n.End() < pos {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			addFields(n.Recv)
+			addFields(n.Type.Params)
+			addFields(n.Type.Results)
+		case *ast.FuncLit:
+			addFields(n.Type.Params)
+			addFields(n.Type.Results)
+		case *ast.BlockStmt:
+			addLocals(n.List...)
+		case *ast.CaseClause:
+			addLocals(n.Body...)
+		case *ast.CommClause:
+			addLocals(append([]ast.Stmt{n.Comm}, n.Body...)...)
+		case *ast.IfStmt:
+			addLocals(n.Init)
+		case *ast.ForStmt:
+			addLocals(n.Init)
+		case *ast.SwitchStmt:
+			addLocals(n.Init)
+		case *ast.TypeSwitchStmt:
+			addLocals(n.Init)
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE && n.Body.Pos() <= pos {
+				for _, e := range []ast.Expr{n.Key, n.Value} {
+					if id, ok := e.(*ast.Ident); ok {
+						add([]*ast.Ident{id}, "var")
+					}
+				}
+			}
+		}
+		return true
+	})
+	return cs
+}
+
+// completeWrapper returns completions for the source given as first argument
+// at the byte offset given as second. The Promise resolves to an array of
+// {label, kind} objects, kind being one of package, func, type, var and
+// const.
+func completeWrapper(this js.Value, args []js.Value) interface{} {
+	return newPromise(func(resolve, reject js.Value) {
+		if argAt(args, 0).Type() != js.TypeString {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+		if argAt(args, 1).Type() != js.TypeNumber {
+			reject.Invoke(errorObject("Invalid or missing offset argument"))
+			return
+		}
+
+		var completions []interface{}
+		for _, c := range complete(args[0].String(), args[1].Int()) {
+			completions = append(completions, map[string]interface{}{"label": c.label, "kind": c.kind})
+		}
+		resolve.Invoke(completions)
+	})
+}
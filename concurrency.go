@@ -0,0 +1,42 @@
+package main
+
+import (
+	"go/ast"
+	"strconv"
+)
+
+// concurrencyNote is the concurrencyNote result field set, when requested,
+// for code that runs goroutines or uses sync primitives.
+const concurrencyNote = "goroutines run on a single thread in WebAssembly, and only switch when one blocks, sleeps or yields; " +
+	"code racing on shared data may therefore never show the race here"
+
+// usesConcurrency reports whether src starts goroutines or imports sync or
+// sync/atomic. Sources that do not parse are taken not to.
+func usesConcurrency(src source) bool {
+	files := src.files
+	if files == nil {
+		files = map[string]string{"": src.code}
+	}
+	for _, code := range files {
+		f := parseFragment(code)
+		if f == nil {
+			continue
+		}
+		for _, spec := range f.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path == "sync" || path == "sync/atomic" {
+				return true
+			}
+		}
+		found := false
+		ast.Inspect(f, func(n ast.Node) bool {
+			if _, ok := n.(*ast.GoStmt); ok {
+				found = true
+			}
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"syscall/js"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// fromJS converts v to a Go value of type t. Numbers, strings, booleans,
+// arrays and plain objects convert to the matching basic, slice and
+// string-keyed map types; empty interfaces take the natural Go counterpart.
+func fromJS(v js.Value, t reflect.Type) (reflect.Value, error) {
+	mismatch := fmt.Errorf("cannot use %s as %s", v.Type(), t)
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Type() != js.TypeBoolean {
+			return out, mismatch
+		}
+		out.SetBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := integer(v)
+		if !ok || out.OverflowInt(int64(f)) {
+			return out, mismatch
+		}
+		out.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f, ok := integer(v)
+		if !ok || f < 0 || out.OverflowUint(uint64(f)) {
+			return out, mismatch
+		}
+		out.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		if v.Type() != js.TypeNumber {
+			return out, mismatch
+		}
+		out.SetFloat(v.Float())
+	case reflect.String:
+		if v.Type() != js.TypeString {
+			return out, mismatch
+		}
+		out.SetString(v.String())
+	case reflect.Slice:
+		if !isArray(v) {
+			return out, mismatch
+		}
+		n := v.Length()
+		out = reflect.MakeSlice(t, n, n)
+		for i := 0; i < n; i++ {
+			e, err := fromJS(v.Index(i), t.Elem())
+			if err != nil {
+				return out, fmt.Errorf("element %d: %w", i, err)
+			}
+			out.Index(i).Set(e)
+		}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || v.Type() != js.TypeObject || isArray(v) {
+			return out, mismatch
+		}
+		out = reflect.MakeMap(t)
+		entries := js.Global().Get("Object").Call("entries", v)
+		for i := 0; i < entries.Length(); i++ {
+			key := entries.Index(i).Index(0).String()
+			e, err := fromJS(entries.Index(i).Index(1), t.Elem())
+			if err != nil {
+				return out, fmt.Errorf("key %q: %w", key, err)
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), e)
+		}
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return out, fmt.Errorf("cannot convert %s to %s", v.Type(), t)
+		}
+		if n := natural(v); n != nil {
+			out.Set(reflect.ValueOf(n))
+		}
+	default:
+		return out, fmt.Errorf("cannot convert %s to %s", v.Type(), t)
+	}
+	return out, nil
+}
+
+// integer returns the value of v when it is an integral number.
+func integer(v js.Value) (float64, bool) {
+	if v.Type() != js.TypeNumber {
+		return 0, false
+	}
+	f := v.Float()
+	return f, f == math.Trunc(f) && !math.IsInf(f, 0)
+}
+
+func isArray(v js.Value) bool {
+	return js.Global().Get("Array").Call("isArray", v).Bool()
+}
+
+// natural converts v to float64, string, bool, []interface{} or
+// map[string]interface{}, or nil for null and undefined.
+func natural(v js.Value) interface{} {
+	switch v.Type() {
+	case js.TypeBoolean:
+		return v.Bool()
+	case js.TypeNumber:
+		return v.Float()
+	case js.TypeString:
+		return v.String()
+	case js.TypeObject:
+		if isArray(v) {
+			s := make([]interface{}, v.Length())
+			for i := range s {
+				s[i] = natural(v.Index(i))
+			}
+			return s
+		}
+		m := map[string]interface{}{}
+		entries := js.Global().Get("Object").Call("entries", v)
+		for i := 0; i < entries.Length(); i++ {
+			m[entries.Index(i).Index(0).String()] = natural(entries.Index(i).Index(1))
+		}
+		return m
+	}
+	return nil
+}
+
+// toJS converts v to a value js.ValueOf accepts. Errors become their message;
+// values without a JS counterpart are formatted with %v.
+func toJS(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(errorType) && v.CanInterface() {
+		if (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && v.IsNil() {
+			return nil
+		}
+		return v.Interface().(error).Error()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = toJS(v.Index(i))
+		}
+		return s
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = toJS(iter.Value())
+		}
+		return m
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			return toJS(v.Elem())
+		}
+	}
+	if !v.CanInterface() {
+		return nil
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
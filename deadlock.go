@@ -0,0 +1,143 @@
+package main
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goroutineID returns the id of the calling goroutine, as printed in stack
+// traces.
+func goroutineID() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	id, _, _ := strings.Cut(strings.TrimPrefix(string(buf), "goroutine "), " ")
+	return id
+}
+
+// goroutineInfo is what blockedStates needs to know about a goroutine.
+type goroutineInfo struct {
+	state, parent string
+	interpreted   bool
+}
+
+// goroutines parses a dump of all goroutines, keyed by id.
+func goroutines() map[string]goroutineInfo {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	all := map[string]goroutineInfo{}
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		header, _, _ := strings.Cut(g, "\n")
+		id, state, _ := strings.Cut(strings.TrimPrefix(header, "goroutine "), " [")
+		state, _, _ = strings.Cut(state, "]")
+		// Drop the duration of long waits, as in "[chan receive, 2 minutes]"
+		state, _, _ = strings.Cut(state, ",")
+		info := goroutineInfo{
+			state: state,
+			// Only interpreted code runs through runCfg
+			interpreted: strings.Contains(g, "yaegi/interp.runCfg"),
+		}
+		if i := strings.LastIndex(g, " in goroutine "); i >= 0 {
+			info.parent, _, _ = strings.Cut(g[i+len(" in goroutine "):], "\n")
+		}
+		all[id] = info
+	}
+	return all
+}
+
+// blockedStates returns the distinct wait states of the goroutines running
+// interpreted code on behalf of goroutine root or its descendants, or nil if
+// any of them makes progress, sleeping included.
+//
+// Goroutines blocked on interpreted channels are released when the
+// interpreter is stopped. Those waiting on a sync primitive never are, and
+// stay parked with the runner they belong to.
+func blockedStates(root string) []string {
+	all := goroutines()
+	run := descendants(all, root)
+	seen := map[string]bool{}
+	for id := range run {
+		g, ok := all[id]
+		if !ok || !g.interpreted {
+			continue
+		}
+		if !isBlockedState(g.state) {
+			return nil
+		}
+		seen[g.state] = true
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	states := make([]string, 0, len(seen))
+	for s := range seen {
+		states = append(states, s)
+	}
+	sort.Strings(states)
+	return states
+}
+
+// descendants returns the ids of root and of the goroutines of all it started,
+// directly or not, that are still alive. A goroutine whose parent is gone is
+// taken as a descendant when the parent was started after root, as goroutine
+// ids only grow; that may count in the goroutines of a later run overlapping
+// this one.
+func descendants(all map[string]goroutineInfo, root string) map[string]bool {
+	first, _ := strconv.Atoi(root)
+	run := map[string]bool{root: true}
+	for grown := true; grown; {
+		grown = false
+		for id, g := range all {
+			if run[id] {
+				continue
+			}
+			_, alive := all[g.parent]
+			parent, _ := strconv.Atoi(g.parent)
+			if run[g.parent] || !alive && parent > first {
+				run[id], grown = true, true
+			}
+		}
+	}
+	return run
+}
+
+// leakedGoroutines returns the number of goroutines started by goroutine
+// root, which has returned, or by their descendants, that are still alive.
+// Goroutines about to return are first given a chance to.
+//
+// Counting descendants rather than comparing runtime.NumGoroutine with a
+// baseline leaves out the runner's own helpers, and the goroutines of
+// overlapping runs.
+func leakedGoroutines(root string) int {
+	for i := 0; i < 3; i++ {
+		runtime.Gosched()
+	}
+	all := goroutines()
+	n := 0
+	for id := range descendants(all, root) {
+		if g, ok := all[id]; ok && g.interpreted && id != root {
+			n++
+		}
+	}
+	return n
+}
+
+// isBlockedState reports whether a goroutine in the given state can only be
+// woken by another goroutine.
+func isBlockedState(state string) bool {
+	for _, prefix := range []string{"chan ", "select", "semacquire", "sync."} {
+		if strings.HasPrefix(state, prefix) {
+			return true
+		}
+	}
+	return false
+}
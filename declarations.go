@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall/js"
+)
+
+// declaration is a top-level identifier declared by evaluated code.
+type declaration struct {
+	name, kind string
+}
+
+// declarations returns the top-level identifiers src declares, in source
+// order, with their kind: var, const, func or type. Statements evaluated at
+// the top level of a fragment declare variables with :=, as in a REPL.
+func declarations(src source) []declaration {
+	files := src.files
+	if files == nil {
+		files = map[string]string{"": src.code}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var decls []declaration
+	for _, name := range names {
+		f := parseFragment(files[name])
+		if f == nil {
+			continue
+		}
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && !isFragmentMain(decl) {
+					decls = append(decls, declaration{decl.Name.Name, "func"})
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						decls = append(decls, declaration{spec.Name.Name, "type"})
+					case *ast.ValueSpec:
+						for _, id := range spec.Names {
+							if id.Name == "_" {
+								continue
+							}
+							decls = append(decls, declaration{id.Name, decl.Tok.String()})
+						}
+					}
+				}
+			}
+		}
+		if len(f.Decls) == 1 && isFragmentMain(f.Decls[0]) {
+			for _, stmt := range f.Decls[0].(*ast.FuncDecl).Body.List {
+				assign, ok := stmt.(*ast.AssignStmt)
+				if !ok || assign.Tok != token.DEFINE {
+					continue
+				}
+				for _, lhs := range assign.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok && id.Name != "_" {
+						decls = append(decls, declaration{id.Name, "var"})
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
+
+// fragmentMain names the function parseFragment wraps statements in.
+const fragmentMain = "_fragment_"
+
+// parseFragment parses code the way yaegi parses what it evaluates. It
+// returns nil if code does not parse.
+func parseFragment(code string) *ast.File {
+	f, err := parser.ParseFile(token.NewFileSet(), "", wrapFragment(code)+code+fragmentEnd(code), parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// wrapFragment returns what to prepend to code for it to parse as a file, as
+// yaegi does: nothing when it has a package clause, a package clause when it
+// starts with a declaration, and the start of a function wrapping statements
+// otherwise, to be closed with fragmentEnd.
+func wrapFragment(code string) string {
+	var s scanner.Scanner
+	s.Init(token.NewFileSet().AddFile("", -1, len(code)), []byte(code), nil, 0)
+	switch _, tok, _ := s.Scan(); tok {
+	case token.PACKAGE:
+		return ""
+	case token.CONST, token.FUNC, token.IMPORT, token.TYPE, token.VAR:
+		return "package main;"
+	}
+	return "package main; func " + fragmentMain + "() {"
+}
+
+// fragmentEnd returns what to append to code wrapped by wrapFragment.
+func fragmentEnd(code string) string {
+	if strings.HasSuffix(wrapFragment(code), "{") {
+		return "\n}"
+	}
+	return ""
+}
+
+func isFragmentMain(decl ast.Decl) bool {
+	fn, ok := decl.(*ast.FuncDecl)
+	return ok && fn.Name.Name == fragmentMain
+}
+
+// describe returns the {name, kind, type, value} object for d, as seen by
+// the interpreter now. Functions have their signature as type, and only
+// variables and constants have a value.
+func (r *runner) describe(d declaration) js.Value {
+	o := js.Global().Get("Object").New()
+	o.Set("name", d.name)
+	o.Set("kind", d.kind)
+	expr := d.name
+	if d.kind == "type" {
+		// Evaluating a type name does not give the type itself
+		expr = "(*" + d.name + ")(nil)"
+	}
+	v, err := r.interp.Eval(expr)
+	if err != nil || !v.IsValid() {
+		return o
+	}
+	t := v.Type()
+	if d.kind == "type" {
+		t = t.Elem()
+	}
+	o.Set("type", t.String())
+	if (d.kind == "var" || d.kind == "const") && v.CanInterface() {
+		o.Set("value", fmt.Sprintf("%v", v.Interface()))
+	}
+	return o
+}
+
+// varPath matches the names getVar accepts: an identifier, possibly followed
+// by selectors, as in pkg.Var or obj.Field.
+var varPath = regexp.MustCompile(`^[\pL_][\pL\pN_]*(\.[\pL_][\pL\pN_]*)*$`)
+
+// getVar returns the {name, defined, kind, type, value} object for the value
+// name refers to now, formatted with %v. defined is false, and type and
+// value empty, when the interpreter cannot evaluate name. kind is "type" for
+// a type, which has no value, and "value" otherwise.
+func (r *runner) getVar(name string) js.Value {
+	o := js.Global().Get("Object").New()
+	o.Set("name", name)
+	o.Set("defined", false)
+	o.Set("kind", "")
+	o.Set("type", "")
+	o.Set("value", "")
+	eval := func(expr string) (v reflect.Value, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("%v", p)
+			}
+		}()
+		return r.interp.Eval(expr)
+	}
+	v, err := eval(name)
+	if err != nil || !v.IsValid() {
+		return o
+	}
+	o.Set("defined", true)
+	// yaegi evaluates a type name to the zero value of the type, so it is
+	// told apart by converting nil to a pointer to it, as describe does
+	if p, err := eval("(*" + name + ")(nil)"); err == nil && p.IsValid() && p.Kind() == reflect.Pointer {
+		o.Set("kind", "type")
+		o.Set("type", p.Type().Elem().String())
+		return o
+	}
+	o.Set("kind", "value")
+	o.Set("type", v.Type().String())
+	if v.CanInterface() {
+		o.Set("value", fmt.Sprintf("%v", v.Interface()))
+	}
+	return o
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// embedError is the error of embedFiles. Like importError, its positions are
+// relative to the source as given.
+type embedError struct{ error }
+
+func (e embedError) Unwrap() error { return e.error }
+
+// errEmbedUnsupported is the message for the uses of //go:embed embedFiles
+// cannot emulate.
+const errEmbedUnsupported = "//go:embed is only supported on a single string or []byte variable, without a value, embedding one file named in full; embed.FS, patterns and directories are not"
+
+// embedFiles emulates //go:embed, which yaegi ignores, by giving each variable
+// under the directive the contents of its file in files, the embedFiles
+// option, as a value. Imports of the embed package, which yaegi lacks, are
+// blanked out. Positions in the returned code are unchanged, but for those
+// following a rewritten declaration on its last line. Code that fails to
+// parse is left for the compiler to report.
+func embedFiles(code string, files map[string]string) (string, error) {
+	if !strings.Contains(code, "//go:embed") && !strings.Contains(code, `"embed"`) {
+		return code, nil
+	}
+	prefix := ""
+	if !isProgram(code) {
+		prefix = fragmentClause
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", prefix+code, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return code, nil
+	}
+	offset := func(pos token.Pos) int { return fset.File(pos).Offset(pos) - len(prefix) }
+	fail := func(pos token.Pos, msg string) error {
+		return embedError{scanner.ErrorList{{Pos: fset.Position(pos), Msg: msg}}}
+	}
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	blank := func(n ast.Node) {
+		start, end := offset(n.Pos()), offset(n.End())
+		edits = append(edits, edit{start, end, strings.Repeat(" ", end-start)})
+	}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if ok && gen.Tok == token.IMPORT {
+			for _, spec := range gen.Specs {
+				if path, _ := strconv.Unquote(spec.(*ast.ImportSpec).Path.Value); path != "embed" {
+					continue
+				}
+				var end token.Pos
+				if gen.Lparen.IsValid() {
+					blank(spec)
+					end = spec.End()
+				} else {
+					blank(gen)
+					end = gen.End()
+				}
+				// With the separating semicolon of a single line, as an
+				// empty spec or declaration does not parse
+				rest := code[offset(end):]
+				if trimmed := strings.TrimLeft(rest, " \t"); strings.HasPrefix(trimmed, ";") {
+					start := offset(end) + len(rest) - len(trimmed)
+					edits = append(edits, edit{start, start + 1, " "})
+				}
+			}
+		}
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, s := range gen.Specs {
+			spec := s.(*ast.ValueSpec)
+			doc := spec.Doc
+			if doc == nil && !gen.Lparen.IsValid() {
+				doc = gen.Doc
+			}
+			directive := embedDirective(doc)
+			if directive == nil {
+				continue
+			}
+			patterns := strings.Fields(strings.TrimPrefix(directive.Text, "//go:embed"))
+			typ := embedType(spec.Type)
+			if len(patterns) != 1 || len(spec.Names) != 1 || spec.Values != nil || typ == "" || strings.ContainsAny(patterns[0], "*?[") {
+				return "", fail(directive.Pos(), errEmbedUnsupported)
+			}
+			data, ok := files[patterns[0]]
+			if !ok {
+				return "", fail(directive.Pos(), fmt.Sprintf("pattern %s: no matching files found", patterns[0]))
+			}
+			value := strconv.Quote(data)
+			if typ == "[]byte" {
+				value = "[]byte(" + value + ")"
+			}
+			end := offset(spec.End())
+			edits = append(edits, edit{end, end, " = " + value})
+		}
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		code = code[:e.start] + e.text + code[e.end:]
+	}
+	return code, nil
+}
+
+// embedDirective returns the //go:embed comment of doc, if any.
+func embedDirective(doc *ast.CommentGroup) *ast.Comment {
+	if doc == nil {
+		return nil
+	}
+	for _, c := range doc.List {
+		if c.Text == "//go:embed" || strings.HasPrefix(c.Text, "//go:embed ") {
+			return c
+		}
+	}
+	return nil
+}
+
+// embedType returns "string" or "[]byte" when expr is one of these types,
+// or "" otherwise.
+func embedType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if t.Name == "string" {
+			return "string"
+		}
+	case *ast.ArrayType:
+		if id, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && (id.Name == "byte" || id.Name == "uint8") {
+			return "[]byte"
+		}
+	}
+	return ""
+}
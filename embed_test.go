@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+func TestEmbedImportOnOneLine(t *testing.T) {
+	for _, code := range []string{
+		`package main; import "embed"; import "fmt"; func main() { fmt.Println("ok") }`,
+		`package main; import ("embed"; "fmt"); func main() { fmt.Println("ok") }`,
+	} {
+		result := executeGo(t, code, nil)
+		if got := result.Get("output").String(); got != "ok\n" {
+			t.Errorf("%s: output = %q, error = %q", code, got, result.Get("error").String())
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"runtime"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// useExit replaces os.Exit, which yaegi turns into a panic, with one ending
+// the run cleanly: the code is recorded as the exitCode result field, the
+// interpreter is stopped and the calling goroutine exits. Unlike the real
+// os.Exit, deferred calls of the exiting goroutine still run.
+func (r *runner) useExit() {
+	exit := func(code int) {
+		if !r.exited {
+			r.exited, r.exitCode = true, code
+			if r.stop != nil {
+				r.stop()
+			}
+		}
+		runtime.Goexit()
+	}
+	r.use(interp.Exports{"os/os": {"Exit": reflect.ValueOf(exit)}})
+}
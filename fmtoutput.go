@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// fmtOutputNote is the fmtOutputNote result field, telling how far fmt
+// output could be told apart from other writes to stdout.
+const fmtOutputNote = "fmtOutput holds what fmt printed to stdout, through Print, Printf and Println, or Fprint and friends given os.Stdout; " +
+	"other writes to os.Stdout, such as its Write method or a bufio.Writer flushing to it, are only in output. " +
+	"os.Stdout is not an *os.File but a writer with Write, WriteString and Sync methods only"
+
+// fmtStdout is the stdout of the interpreter, which fmt's Print functions
+// write to.
+type fmtStdout struct{ r *runner }
+
+func (w fmtStdout) Write(p []byte) (int, error) {
+	if w.r.opts.fmtOutput {
+		w.r.fmtOutput.Write(p)
+	}
+	return w.r.stdout.Write(p)
+}
+
+// stdoutFile is os.Stdout for interpreted code. yaegi leaves os.Stdout and
+// os.Stderr the host's for any other stdout and stderr than an *os.File, so
+// that writes to them are otherwise not captured at all.
+type stdoutFile struct{ r *runner }
+
+func (f *stdoutFile) Write(p []byte) (int, error) {
+	if f.r.opts.fmtOutput && calledFromFmt() {
+		f.r.fmtOutput.Write(p)
+	}
+	return f.r.stdout.Write(p)
+}
+
+func (f *stdoutFile) WriteString(s string) (int, error) { return f.Write([]byte(s)) }
+
+// Sync does nothing, as nothing is buffered.
+func (f *stdoutFile) Sync() error { return nil }
+
+// stderrFile is os.Stderr for interpreted code; see stdoutFile.
+type stderrFile struct{ r *runner }
+
+func (f *stderrFile) Write(p []byte) (int, error) { return f.r.stderr.Write(p) }
+
+func (f *stderrFile) WriteString(s string) (int, error) { return f.Write([]byte(s)) }
+
+// Sync does nothing, as nothing is buffered.
+func (f *stderrFile) Sync() error { return nil }
+
+// calledFromFmt reports whether one of fmt's Fprint functions is among the
+// callers, as when interpreted code passes os.Stdout to it.
+func calledFromFmt() bool {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(3, pc)])
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "fmt.Fprint") {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// useStdFiles makes os.Stdout a stdoutFile and os.Stderr a stderrFile.
+func (r *runner) useStdFiles() {
+	r.osStdout, r.osStderr = &stdoutFile{r}, &stderrFile{r}
+	r.use(interp.Exports{"os/os": {
+		"Stdout": reflect.ValueOf(&r.osStdout).Elem(),
+		"Stderr": reflect.ValueOf(&r.osStderr).Elem(),
+	}})
+}
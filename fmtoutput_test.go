@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStdFilesTranscript(t *testing.T) {
+	code := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println("out 1")
+	fmt.Fprintln(os.Stderr, "err 1")
+	os.Stdout.Write([]byte("out 2\n"))
+	os.Stderr.WriteString("err 2\n")
+	fmt.Fprintln(os.Stdout, "out 3")
+}`
+	result := executeGo(t, code, map[string]interface{}{"transcript": true})
+	if got, want := result.Get("output").String(), "out 1\nout 2\nout 3\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if got, want := result.Get("error").String(), "err 1\nerr 2\n"; got != want {
+		t.Errorf("error = %q, want %q", got, want)
+	}
+
+	var got [][2]string
+	entries := result.Get("transcript")
+	for i := 0; i < entries.Length(); i++ {
+		e := entries.Index(i)
+		got = append(got, [2]string{e.Get("stream").String(), e.Get("text").String()})
+	}
+	want := [][2]string{
+		{"stdout", "out 1\n"},
+		{"stderr", "err 1\n"},
+		{"stdout", "out 2\n"},
+		{"stderr", "err 2\n"},
+		{"stdout", "out 3\n"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("transcript = %q, want %q", got, want)
+	}
+}
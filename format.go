@@ -0,0 +1,29 @@
+package main
+
+import (
+	"go/format"
+	"syscall/js"
+)
+
+// formatGoCodeWrapper gofmts the source given as first argument without
+// evaluating it. The Promise resolves to {formatted, error}, where error
+// carries the line:column of the first parse error.
+func formatGoCodeWrapper(this js.Value, args []js.Value) interface{} {
+	return newPromise(func(resolve, reject js.Value) {
+		if len(args) == 0 || args[0].Type() != js.TypeString {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+
+		result := js.Global().Get("Object").New()
+		formatted, err := format.Source([]byte(args[0].String()))
+		if err != nil {
+			result.Set("formatted", "")
+			result.Set("error", err.Error())
+		} else {
+			result.Set("formatted", string(formatted))
+			result.Set("error", "")
+		}
+		resolve.Invoke(result)
+	})
+}
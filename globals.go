@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"maps"
+	"path"
+	"reflect"
+	"unsafe"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// yaegi has no API resetting an interpreter, so resetGlobals restores, by
+// reflection, the unexported fields of the yaegi v0.16.1 Interpreter holding
+// what evaluated code declares: the symbols and frame layouts of the universe
+// and package scopes, the values of the global frame, the source and binary
+// packages, and the nodes and generics compiled. Evaluated code may also
+// have assigned the variables of the binary packages, os.Args and os.Stdout
+// among them, or changed what yaegi keeps for each interpreter behind them:
+// its environment, its flag.CommandLine and the logger of the log functions.
+// Those are restored too. Anything else an eval leaves behind, such as the
+// positions of its sources in the file set, is unreachable from code
+// compiled afterwards.
+
+// interpFields are the fields of the Interpreter that globalsSnapshot
+// copies, with the depth of maps to copy: srcPkg maps import paths to maps
+// of symbols that imports add to. binPkg, which Use adds to likewise, is
+// copied on write instead; see use.
+var interpFields = map[string]int{
+	"srcPkg":   2,
+	"mapTypes": 1,
+	"pkgNames": 1,
+	"rdir":     1,
+	"generic":  1,
+	"roots":    1,
+	"scopes":   1,
+	"env":      1,
+}
+
+// scopeFields are the fields of a scope that globalsSnapshot copies.
+var scopeFields = []string{"sym", "types", "child"}
+
+// globalsFieldsFound tells whether the Interpreter has the fields
+// resetGlobals restores. Another version of yaegi may not, and runners are
+// then never reused.
+var globalsFieldsFound = hasGlobalsFields()
+
+func hasGlobalsFields() bool {
+	has := func(t reflect.Type, names ...string) bool {
+		for _, name := range names {
+			if _, ok := t.FieldByName(name); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	i := reflect.TypeOf((*interp.Interpreter)(nil)).Elem()
+	for name := range interpFields {
+		if !has(i, name) {
+			return false
+		}
+	}
+	binPkg, ok := i.FieldByName("binPkg")
+	if !ok || binPkg.Type != reflect.TypeOf(interp.Exports{}) {
+		return false
+	}
+	universe, ok := i.FieldByName("universe")
+	if !ok || universe.Type.Kind() != reflect.Pointer || !has(universe.Type.Elem(), scopeFields...) {
+		return false
+	}
+	frame, ok := i.FieldByName("frame")
+	return ok && frame.Type.Kind() == reflect.Pointer && has(frame.Type.Elem(), "data")
+}
+
+// globalsSnapshot is the state of an interpreter restored by resetGlobals.
+type globalsSnapshot struct {
+	// binPkg holds the symbols of the binary packages, and written the
+	// packages use has given symbols of their own since
+	binPkg  interp.Exports
+	written map[string]bool
+	fields  map[string]reflect.Value
+	// scopes are the universe and package scopes, each with the copy of
+	// its scopeFields
+	scopes []scopeSnapshot
+	data   reflect.Value
+	// vars are the variables of the binary packages, with their values
+	vars []varSnapshot
+}
+
+type varSnapshot struct {
+	v, value reflect.Value
+}
+
+type scopeSnapshot struct {
+	scope  reflect.Value
+	fields map[string]reflect.Value
+}
+
+// field returns the field name of the struct v, which must be addressable,
+// settable even though unexported.
+func field(v reflect.Value, name string) reflect.Value {
+	f := v.FieldByName(name)
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
+
+// copyValue returns a copy of the map or slice v, copying the maps it holds
+// too, down to depth levels. Other values are returned as is.
+func copyValue(v reflect.Value, depth int) reflect.Value {
+	switch {
+	case v.Kind() == reflect.Map && !v.IsNil():
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			value := iter.Value()
+			if depth > 1 {
+				value = copyValue(value, depth-1)
+			}
+			c.SetMapIndex(iter.Key(), value)
+		}
+		return c
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		return c
+	}
+	return v
+}
+
+// snapshotGlobals records the state resetGlobals restores.
+func (r *runner) snapshotGlobals() *globalsSnapshot {
+	i := reflect.ValueOf(r.interp).Elem()
+	s := &globalsSnapshot{
+		binPkg:  maps.Clone(r.binPkg()),
+		written: map[string]bool{},
+		fields:  map[string]reflect.Value{},
+	}
+	for name, depth := range interpFields {
+		s.fields[name] = copyValue(field(i, name), depth)
+	}
+	scopes := []reflect.Value{field(i, "universe")}
+	for iter := field(i, "scopes").MapRange(); iter.Next(); {
+		scopes = append(scopes, iter.Value())
+	}
+	for _, sc := range scopes {
+		saved := scopeSnapshot{scope: sc.Elem(), fields: map[string]reflect.Value{}}
+		for _, name := range scopeFields {
+			saved.fields[name] = copyValue(field(saved.scope, name), 1)
+		}
+		s.scopes = append(s.scopes, saved)
+	}
+	s.data = copyValue(field(field(i, "frame").Elem(), "data"), 1)
+	for _, symbols := range s.binPkg {
+		for _, v := range symbols {
+			if v.CanSet() {
+				value := reflect.New(v.Type()).Elem()
+				value.Set(v)
+				s.vars = append(s.vars, varSnapshot{v, value})
+			}
+		}
+	}
+	return s
+}
+
+// resetGlobals brings the interpreter back to the state of r.globals, taken
+// once newRunner set it up: what the evals since declared, the imports
+// included, is forgotten, as are the symbols configure shadowed for them.
+// It must not be called while code compiled before may still run, as that
+// code keeps referring to the frame whose values are reset.
+func (r *runner) resetGlobals() {
+	i := reflect.ValueOf(r.interp).Elem()
+	field(i, "binPkg").Set(reflect.ValueOf(maps.Clone(r.globals.binPkg)))
+	clear(r.globals.written)
+	for name, depth := range interpFields {
+		field(i, name).Set(copyValue(r.globals.fields[name], depth))
+	}
+	for _, saved := range r.globals.scopes {
+		for _, name := range scopeFields {
+			field(saved.scope, name).Set(copyValue(saved.fields[name], 1))
+		}
+	}
+	field(field(i, "frame").Elem(), "data").Set(copyValue(r.globals.data, 1))
+	for _, saved := range r.globals.vars {
+		saved.v.Set(saved.value)
+	}
+	if v := r.binPkg()["flag"]["CommandLine"]; v.IsValid() {
+		old := v.Interface().(*flag.FlagSet)
+		c := flag.NewFlagSet(old.Name(), old.ErrorHandling())
+		c.SetOutput(old.Output())
+		v.Set(reflect.ValueOf(c))
+	}
+	r.resetLog()
+
+	// The runner's records of what it made the interpreter import or use
+	// are out of date too
+	r.autoImported = nil
+	r.packages.files = nil
+	r.osStdin = nil
+}
+
+// binPkg returns the symbols of the binary packages of the interpreter.
+func (r *runner) binPkg() interp.Exports {
+	return field(reflect.ValueOf(r.interp).Elem(), "binPkg").Interface().(interp.Exports)
+}
+
+// use is Interpreter.Use, once the symbols of the packages of exports, when
+// still those of r.globals, are copied, since Use writes into them. Copying
+// those of all packages on every reset would take longer than building a new
+// interpreter.
+func (r *runner) use(exports interp.Exports) {
+	if r.globals != nil {
+		binPkg := r.binPkg()
+		for key := range exports {
+			dir := path.Dir(key)
+			if symbols := binPkg[dir]; symbols != nil && !r.globals.written[dir] {
+				binPkg[dir] = maps.Clone(symbols)
+			}
+			r.globals.written[dir] = true
+		}
+	}
+	r.interp.Use(exports)
+}
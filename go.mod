@@ -2,4 +2,4 @@ module github.com/lojban/booker
 
 go 1.24.2
 
-require github.com/traefik/yaegi v0.16.1 // indirect
+require github.com/traefik/yaegi v0.16.1
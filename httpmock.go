@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// httpMock is the response served for a URL of the httpMock option.
+type httpMock struct {
+	status int
+	body   string
+	header http.Header
+}
+
+// parseHTTPMocks converts the httpMock option, an object mapping URLs either
+// to a response body, served with status 200, or to a {status, body, headers}
+// object, or returns nil when v is not an object.
+func parseHTTPMocks(v js.Value) map[string]httpMock {
+	if v.Type() != js.TypeObject {
+		return nil
+	}
+	entries := js.Global().Get("Object").Call("entries", v)
+	mocks := make(map[string]httpMock, entries.Length())
+	for i := 0; i < entries.Length(); i++ {
+		e := entries.Index(i)
+		mock := httpMock{status: http.StatusOK, header: http.Header{}}
+		switch resp := e.Index(1); resp.Type() {
+		case js.TypeObject:
+			if status := resp.Get("status"); status.Type() == js.TypeNumber {
+				mock.status = status.Int()
+			}
+			if body := resp.Get("body"); body.Truthy() {
+				mock.body = js.Global().Get("String").Invoke(body).String()
+			}
+			for key, value := range stringMap(resp.Get("headers")) {
+				mock.header.Set(key, value)
+			}
+		default:
+			mock.body = js.Global().Get("String").Invoke(resp).String()
+		}
+		mocks[e.Index(0).String()] = mock
+	}
+	return mocks
+}
+
+// mockTransport serves requests from mocks, never reaching the network.
+type mockTransport struct {
+	mocks map[string]httpMock
+}
+
+func (t mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	mock, ok := t.mocks[req.URL.String()]
+	if !ok {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("no mock configured for URL %s", req.URL)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", mock.status, http.StatusText(mock.status)),
+		StatusCode:    mock.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        mock.header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(mock.body)),
+		ContentLength: int64(len(mock.body)),
+		Request:       req,
+	}, nil
+}
+
+// mockClient shadows http.Client in the interpreter. The zero value of a
+// real one sends requests through the host's http.DefaultTransport, which
+// reaches the network whatever the interpreter sees as DefaultTransport, and
+// the fields of a client the code builds tell nothing of the runner, so one
+// without a Transport fails instead.
+type mockClient struct {
+	Transport     http.RoundTripper
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+	Jar           http.CookieJar
+	Timeout       time.Duration
+}
+
+// unmockedTransport is the transport of mockClients without one.
+type unmockedTransport struct{}
+
+func (unmockedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, fmt.Errorf("no mock configured for URL %s: clients without a Transport are not mocked, use http.DefaultClient", req.URL)
+}
+
+// client returns the http.Client c stands for.
+func (c *mockClient) client() *http.Client {
+	transport := c.Transport
+	if transport == nil {
+		transport = unmockedTransport{}
+	}
+	return &http.Client{Transport: transport, CheckRedirect: c.CheckRedirect, Jar: c.Jar, Timeout: c.Timeout}
+}
+
+func (c *mockClient) Do(req *http.Request) (*http.Response, error) { return c.client().Do(req) }
+
+func (c *mockClient) Get(url string) (*http.Response, error) { return c.client().Get(url) }
+
+func (c *mockClient) Head(url string) (*http.Response, error) { return c.client().Head(url) }
+
+func (c *mockClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	return c.client().Post(url, contentType, body)
+}
+
+func (c *mockClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	return c.client().PostForm(url, data)
+}
+
+func (c *mockClient) CloseIdleConnections() { c.client().CloseIdleConnections() }
+
+// mockHTTP makes the default client and transport of net/http in the
+// interpreter, and the functions using them such as http.Get, serve the
+// responses of mocks, failing for any other URL. http.Client is shadowed by
+// mockClient, so that clients the code builds without a transport fail
+// rather than use the real one, which the host shares.
+func (r *runner) mockHTTP(mocks map[string]httpMock) {
+	transport := http.RoundTripper(mockTransport{mocks})
+	client := &mockClient{Transport: transport}
+	r.use(interp.Exports{"net/http/http": {
+		"Client":           reflect.ValueOf((*mockClient)(nil)),
+		"DefaultClient":    reflect.ValueOf(&client).Elem(),
+		"DefaultTransport": reflect.ValueOf(&transport).Elem(),
+		"Get":              reflect.ValueOf(func(url string) (*http.Response, error) { return client.Get(url) }),
+		"Head":             reflect.ValueOf(func(url string) (*http.Response, error) { return client.Head(url) }),
+		"Post": reflect.ValueOf(func(url, contentType string, body io.Reader) (*http.Response, error) {
+			return client.Post(url, contentType, body)
+		}),
+		"PostForm": reflect.ValueOf(func(url string, data url.Values) (*http.Response, error) {
+			return client.PostForm(url, data)
+		}),
+	}})
+}
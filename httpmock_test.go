@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMockHTTPOwnClient(t *testing.T) {
+	code := `package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+func get(c *http.Client) {
+	resp, err := c.Get("https://example.com/a")
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Println(resp.StatusCode, string(body))
+}
+
+func main() {
+	get(&http.Client{})
+	get(&http.Client{Timeout: time.Second})
+	get(&http.Client{Transport: http.DefaultTransport})
+	get(http.DefaultClient)
+}`
+	mocks := map[string]interface{}{"https://example.com/a": "mocked"}
+	result := executeGo(t, code, map[string]interface{}{"httpMock": mocks})
+	if e := result.Get("error").String(); e != "" {
+		t.Fatalf("error = %q", e)
+	}
+	lines := strings.Split(strings.TrimSpace(result.Get("output").String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("output = %q, want 4 lines", lines)
+	}
+	for _, line := range lines[:2] {
+		if !strings.Contains(line, "no mock configured") {
+			t.Errorf("client without a Transport: %q, want no mock configured", line)
+		}
+	}
+	for _, line := range lines[2:] {
+		if line != "200 mocked" {
+			t.Errorf("mocked client: %q, want 200 mocked", line)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"maps"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// fragmentClause is prepended to fragments without a package clause so that
+// they parse as a file, as yaegi does. The //line directive keeps positions
+// relative to the original source.
+const fragmentClause = "package main\n//line :1:1\n"
+
+// parseImports parses the import declarations of code.
+func parseImports(code string) (*token.FileSet, []*ast.ImportSpec, error) {
+	if !isProgram(code) {
+		code = fragmentClause + code
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", code, parser.ImportsOnly)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fset, f.Imports, nil
+}
+
+// importError is the error of checkImports. Unlike those of yaegi, its
+// positions are relative to the source as given.
+type importError struct{ error }
+
+func (e importError) Unwrap() error { return e.error }
+
+// checkImports rejects code importing a package that is not in the
+// allowedImports option, when set, or that is in deniedImports,
+// disabledPackages or, in safe mode, safeModePackages, and code importing a
+// package that is neither known to the interpreter nor in local, the user
+// packages given along with it. Packages in local are always allowed. Code
+// that fails to parse is left for the compiler to report.
+func checkImports(code string, opts runOptions, local map[string]bool) error {
+	fset, imports, err := parseImports(code)
+	if err != nil {
+		return nil
+	}
+
+	for _, spec := range imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		msg := ""
+		switch {
+		case local[path]:
+		case (opts.allowedImports != nil && !opts.allowedImports[path]) || opts.deniedImports[path]:
+			msg = fmt.Sprintf("import %q is not allowed", path)
+		case opts.safeMode && safeModePackages[path]:
+			msg = fmt.Sprintf("package %s is disabled in safe mode", path)
+		case opts.disabledPackages[path]:
+			msg = fmt.Sprintf("package %s not available in this exercise", path)
+		case packageKey(path) == "":
+			msg = fmt.Sprintf("package %q is neither in the standard library nor among the given packages", path)
+		}
+		if msg != "" {
+			return importError{scanner.ErrorList{{Pos: fset.Position(spec.Path.Pos()), Msg: msg}}}
+		}
+	}
+	return nil
+}
+
+// autoImport imports into the interpreter's scope the packages of the
+// autoImport option that src does not import itself, as yaegi rejects
+// importing a package twice, and that no earlier call imported. Code can then
+// use them without import declarations, whether a program or a fragment. As
+// with any package imported twice, a later eval of a session importing one of
+// them again fails.
+func (r *runner) autoImport(src source) error {
+	files := src.files
+	if files == nil {
+		files = map[string]string{"": src.code}
+	}
+	skip := map[string]bool{}
+	for _, code := range files {
+		if _, imports, err := parseImports(code); err == nil {
+			for _, spec := range imports {
+				path, _ := strconv.Unquote(spec.Path.Value)
+				skip[path] = true
+			}
+		}
+	}
+
+	for _, path := range r.opts.autoImports {
+		if skip[path] || r.autoImported[path] {
+			continue
+		}
+		if _, err := r.interp.Eval("import " + strconv.Quote(path)); err != nil {
+			return fmt.Errorf("auto-import: %v", err)
+		}
+		if r.autoImported == nil {
+			r.autoImported = map[string]bool{}
+		}
+		r.autoImported[path] = true
+	}
+	return nil
+}
+
+// yaegiPackages prefixes the import paths of yaegi's own packages. stdlib
+// includes that of its stdlib package, whose Symbols variable holds the whole
+// symbol table, packages left out by stdlibSymbols included, so none is ever
+// made available to interpreted code.
+const yaegiPackages = "github.com/traefik/yaegi/"
+
+// isYaegiPackage reports whether importPath is one of yaegiPackages.
+func isYaegiPackage(importPath string) bool {
+	return strings.HasPrefix(importPath, yaegiPackages)
+}
+
+// stdlibSymbols returns stdlib.Symbols without yaegi's own packages, those of
+// the disabledPackages option and, in safe mode, those of safeModePackages
+// and the symbols of safeModeSymbols, with those of safeModeWrappers
+// replaced. fmt is kept whatever disabledPackages says, since yaegi only
+// redirects the standard streams of interpreted code when given it;
+// checkImports still rejects it.
+func stdlibSymbols(opts runOptions) interp.Exports {
+	symbols := interp.Exports{}
+	for key, values := range stdlib.Symbols {
+		dir := path.Dir(key)
+		if isYaegiPackage(dir) || (opts.disabledPackages[dir] || opts.safeMode && safeModePackages[dir]) && dir != "fmt" {
+			continue
+		}
+		if opts.safeMode && (safeModeSymbols[dir] != nil || safeModeWrappers[dir] != nil) {
+			values = maps.Clone(values)
+			for _, name := range safeModeSymbols[dir] {
+				delete(values, name)
+			}
+			maps.Copy(values, safeModeWrappers[dir])
+		}
+		symbols[key] = values
+	}
+	return symbols
+}
+
+// listImportsWrapper parses the import declarations of the code given as
+// first argument, a source string or a map of files as for executeGoCode,
+// without running it. The Promise resolves to {imports, error}, imports being
+// an array of {path, alias, line} objects, with the file of each for a map of
+// files, sorted by file name. alias is empty for imports without a name.
+func listImportsWrapper(this js.Value, args []js.Value) interface{} {
+	return newPromise(func(resolve, reject js.Value) {
+		v := argAt(args, 0)
+		files := map[string]string{"": v.String()}
+		switch {
+		case v.Type() == js.TypeObject && !isArray(v):
+			files = stringMap(v)
+		case v.Type() != js.TypeString:
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		imports := []interface{}{}
+		result := js.Global().Get("Object").New()
+		result.Set("error", "")
+		for _, name := range names {
+			fset, specs, err := parseImports(files[name])
+			if err != nil {
+				if name != "" {
+					err = fmt.Errorf("%s: %v", name, err)
+				}
+				result.Set("error", err.Error())
+				imports = []interface{}{}
+				break
+			}
+			for _, spec := range specs {
+				path, _ := strconv.Unquote(spec.Path.Value)
+				imp := map[string]interface{}{
+					"path":  path,
+					"alias": "",
+					"line":  fset.Position(spec.Path.Pos()).Line,
+				}
+				if spec.Name != nil {
+					imp["alias"] = spec.Name.Name
+				}
+				if name != "" {
+					imp["file"] = name
+				}
+				imports = append(imports, imp)
+			}
+		}
+		result.Set("imports", imports)
+		resolve.Invoke(result)
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"syscall/js"
+
+	"github.com/traefik/yaegi/stdlib"
+)
+
+const yaegiModule = "github.com/traefik/yaegi"
+
+// runnerInfoWrapper returns the versions the runner was built with, and the
+// top-level stdlib package paths (such as "fmt" or "encoding") that can be
+// imported.
+func runnerInfoWrapper(this js.Value, args []js.Value) interface{} {
+	yaegiVersion := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == yaegiModule {
+				yaegiVersion = dep.Version
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	var packages []interface{}
+	for _, p := range stdlibPackages() {
+		top, _, _ := strings.Cut(p, "/")
+		if !seen[top] {
+			seen[top] = true
+			packages = append(packages, top)
+		}
+	}
+
+	info := js.Global().Get("Object").New()
+	info.Set("yaegiVersion", yaegiVersion)
+	info.Set("goVersion", runtime.Version())
+	info.Set("packages", packages)
+	return info
+}
+
+// stdlibPackages returns the sorted import paths of stdlib.Symbols.
+func stdlibPackages() []string {
+	packages := make([]string, 0, len(stdlib.Symbols))
+	for key := range stdlib.Symbols {
+		// Keys are of the form "import/path/name", except for the "." entry
+		// holding interface wrappers
+		if key != "." && !isYaegiPackage(path.Dir(key)) {
+			packages = append(packages, path.Dir(key))
+		}
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+// listPackagesWrapper returns the full import paths of all stdlib packages
+// available to interpreted code.
+func listPackagesWrapper(this js.Value, args []js.Value) interface{} {
+	var packages []interface{}
+	for _, p := range stdlibPackages() {
+		packages = append(packages, p)
+	}
+	return packages
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"syscall/js"
+	"unicode"
+	"unicode/utf8"
+)
+
+// evalToJSONWrapper runs the code given as first argument like
+// executeGoCodeWrapper, with the same options, usually an expression for a
+// value to inspect. The result has a json field with the value it evaluates
+// to as indented JSON, or null for programs and code without a value.
+// Values JSON cannot represent, such as channels and functions, and those of
+// types with unexported fields, which it would leave out, are formatted with
+// %+v instead, with the reason in the jsonNote field.
+func evalToJSONWrapper(this js.Value, args []js.Value) interface{} {
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		code, warning := preprocess(argAt(args, 0))
+		src, ok := parseSource(code)
+		if !ok {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+
+		opts := parseRunOptions(argAt(args, 1))
+		opts.toJSON = true
+		r := acquireRunner(opts)
+		result := r.eval(ctx, src, opts.timeout)
+		addWarning(result, warning)
+		r.settle(result, resolve, reject)
+		warmSpareRunner()
+	})
+}
+
+// jsonValue returns v marshalled to indented JSON or, when that would not
+// show it faithfully, formatted with %+v along with a note saying why.
+func jsonValue(v reflect.Value) (text, note string) {
+	if hasUnexported(v.Type(), map[reflect.Type]bool{}) {
+		return fmt.Sprintf("%+v", v.Interface()), "the value has unexported fields, which JSON leaves out"
+	}
+	b, err := json.MarshalIndent(v.Interface(), "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%+v", v.Interface()), err.Error()
+	}
+	return string(b), ""
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// hasUnexported reports whether values of t may hold structs with
+// unexported fields that encoding/json would marshal on its own. yaegi
+// exports the fields of interpreted structs, prefixing those that are not
+// with an X, so a field such as Xb is taken for an unexported b.
+func hasUnexported(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	if t.Implements(jsonMarshaler) || t.Implements(textMarshaler) ||
+		reflect.PointerTo(t).Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(textMarshaler) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return hasUnexported(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || isRenamedUnexported(f.Name) {
+				return true
+			}
+			if hasUnexported(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isRenamedUnexported reports whether name is that yaegi gives an
+// unexported field: X followed by the original name.
+func isRenamedUnexported(name string) bool {
+	if len(name) < 2 || name[0] != 'X' {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(name[1:])
+	return !unicode.IsUpper(r)
+}
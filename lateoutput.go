@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// defaultLateOutputGrace is how long a run waits by default, once main has
+// returned, for the goroutines it leaves running: not at all, as the wait is
+// opt-in.
+const defaultLateOutputGrace = time.Duration(0)
+
+// awaitLateOutput waits for the goroutines of the run rooted at r.root still
+// alive once main returned to end, for the lateOutputGrace option at most,
+// and returns the stdout they wrote meanwhile, from offset from. It returns
+// early once they are all blocked, as they cannot print any more until the
+// run is stopped, or when ctx is done.
+//
+// Goroutines still running past the grace period are abandoned with the
+// run: what they print after that is not part of any result.
+func (r *runner) awaitLateOutput(ctx context.Context, from int) string {
+	deadline := time.Now().Add(r.opts.lateOutputGrace)
+	for r.leaked > 0 && time.Now().Before(deadline) && ctx.Err() == nil && blockedStates(r.root) == nil {
+		time.Sleep(min(5*time.Millisecond, time.Until(deadline)))
+		r.leaked = leakedGoroutines(r.root)
+	}
+	late := string(r.stdout.Bytes()[from:])
+	if r.opts.stripAnsi {
+		late = ansiEscape.ReplaceAllString(late, "")
+	}
+	return late
+}
@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+func TestLateOutputOptIn(t *testing.T) {
+	code := "package main\n\nimport (\n\t\"fmt\"\n\t\"time\"\n)\n\nfunc main() {\n\tgo func() {\n\t\ttime.Sleep(10 * time.Millisecond)\n\t\tfmt.Println(\"late\")\n\t}()\n}"
+	if late := executeGo(t, code, nil).Get("lateOutput").String(); late != "" {
+		t.Errorf("lateOutput without lateOutputGrace = %q, want none", late)
+	}
+	if late := executeGo(t, code, map[string]interface{}{"lateOutputGrace": 500}).Get("lateOutput").String(); late != "late\n" {
+		t.Errorf("lateOutput with lateOutputGrace = %q, want %q", late, "late\n")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"syscall/js"
+)
+
+// The run started by the last executeLatest call, which any newer call
+// supersedes.
+var (
+	latestMu     sync.Mutex
+	latestRun    int
+	latestCancel context.CancelFunc
+)
+
+// executeLatestWrapper behaves like executeGoCodeWrapper, except that each
+// call stops the run of the previous one, if still in progress. The Promise of
+// a call superseded by a newer one, whether it was stopped or had already
+// ended, resolves to {superseded: true} instead of a result.
+func executeLatestWrapper(this js.Value, args []js.Value) interface{} {
+	// The previous run is superseded as soon as the call is made, rather
+	// than once the goroutine of the Promise runs, which waits for the
+	// previous run to yield when it does not block
+	latest, supersede := context.WithCancel(context.Background())
+	latestMu.Lock()
+	if latestCancel != nil {
+		latestCancel()
+	}
+	latestRun++
+	id := latestRun
+	latestCancel = supersede
+	latestMu.Unlock()
+
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		defer supersede()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		defer context.AfterFunc(latest, cancel)()
+
+		code, warning := preprocess(argAt(args, 0))
+		src, ok := parseSource(code)
+		if !ok {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+		opts := parseRunOptions(argAt(args, 1))
+		r := acquireRunner(opts)
+		result := r.eval(ctx, src, opts.timeout)
+		warmSpareRunner()
+
+		latestMu.Lock()
+		superseded := id != latestRun
+		if !superseded {
+			latestCancel = nil
+		}
+		latestMu.Unlock()
+		if superseded {
+			result = js.Global().Get("Object").New()
+			result.Set("superseded", true)
+			resolve.Invoke(result)
+			return
+		}
+		addWarning(result, warning)
+		r.settle(result, resolve, reject)
+	})
+}
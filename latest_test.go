@@ -0,0 +1,39 @@
+package main
+
+import (
+	"syscall/js"
+	"testing"
+	"time"
+)
+
+func TestExecuteLatestSupersedesSpinning(t *testing.T) {
+	execute := func(code string) js.Value {
+		return executeLatestWrapper(js.Undefined(), []js.Value{js.ValueOf(code), js.ValueOf(map[string]interface{}{"timeout": 5000})}).(js.Value)
+	}
+	spinning := execute("for {}")
+	// A newer run started from JS while the first spins, as from a click
+	// handler, supersedes it, and so does one started right after it
+	latest := make(chan js.Value, 2)
+	start := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		spinning2 := execute("for {}")
+		latest <- execute(`import "fmt"; fmt.Print(7)`)
+		latest <- spinning2
+		return nil
+	})
+	defer start.Release()
+	js.Global().Call("setTimeout", start, 500)
+
+	begin := time.Now()
+	if result := awaitValue(spinning); !result.Get("superseded").Truthy() {
+		t.Errorf("spinning run: status %q, want superseded", result.Get("status").String())
+	}
+	if elapsed := time.Since(begin); elapsed > 3*time.Second {
+		t.Errorf("took %v to supersede the spinning run", elapsed)
+	}
+	if got := awaitValue(<-latest).Get("output").String(); got != "7" {
+		t.Errorf("latest run: output %q, want 7", got)
+	}
+	if result := awaitValue(<-latest); !result.Get("superseded").Truthy() {
+		t.Errorf("run started right before the latest: status %q, want superseded", result.Get("status").String())
+	}
+}
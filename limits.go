@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+// Errors of the runs stopped by watchLimits.
+var (
+	errMemoryLimit    = errors.New("memory limit exceeded")
+	errGoroutineLimit = errors.New("goroutine limit exceeded")
+	errOutputLimit    = errors.New("output limit exceeded")
+)
+
+// limitCheckInterval is how often watchLimits samples the run.
+const limitCheckInterval = 10 * time.Millisecond
+
+// heapAlloc returns the bytes of allocated heap objects, recording the peak
+// observed during the run.
+func (r *runner) heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	if m.HeapAlloc > r.peakHeap {
+		r.peakHeap = m.HeapAlloc
+	}
+	return m.HeapAlloc
+}
+
+// codeGoroutines returns the number of goroutines started by the code of the
+// run spawned on goroutine root. Of its descendants, only the one yaegi
+// executes the code on is the runner's.
+func codeGoroutines(root string) int {
+	all := goroutines()
+	n := -1
+	for id := range descendants(all, root) {
+		if _, ok := all[id]; ok && id != root {
+			n++
+		}
+	}
+	return n
+}
+
+// stopOutput stops the run in progress, if any, once one of its outputs has
+// reached the maxOutputBytes option, with the stopOnOutputLimit option. Code
+// printing in a loop otherwise keeps running until its timeout with its
+// output dropped.
+func (r *runner) stopOutput() {
+	if r.opts.stopOnOutputLimit && r.stop != nil && r.exceeded == nil {
+		r.exceeded = errOutputLimit
+		r.stop()
+	}
+}
+
+// watchLimits samples the run until done is closed, and stops it with r.stop
+// once the heap has grown by more than the maxMemoryBytes option since the
+// run started, or the code has more goroutines running than the
+// maxGoroutines option. The heap is process-wide, so overlapping runs count
+// against each other's memory limit. Goroutines are only told apart, which
+// takes a dump of all of them, once there are more in the whole process than
+// the limit.
+//
+// The wasm scheduler never preempts, so samples are only taken while the run
+// blocks or yields, as it does in its loops and function calls; see
+// addYields. A single allocation too large for the heap escapes them.
+func (r *runner) watchLimits(done <-chan struct{}) {
+	heap := r.heapAlloc()
+	ticker := time.NewTicker(limitCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			switch {
+			case r.opts.maxMemory > 0 && r.heapAlloc() > heap+uint64(r.opts.maxMemory):
+				r.exceeded = errMemoryLimit
+			case r.opts.maxGoroutines > 0 && runtime.NumGoroutine() > r.opts.maxGoroutines && codeGoroutines(r.root) > r.opts.maxGoroutines:
+				r.exceeded = errGoroutineLimit
+			default:
+				continue
+			}
+			r.stop()
+			return
+		}
+	}
+}
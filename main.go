@@ -1,68 +1,143 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"fmt"
+	"runtime"
 	"syscall/js"
-
-	"github.com/traefik/yaegi/interp"
-	"github.com/traefik/yaegi/stdlib"
 )
 
-type outputCapturer struct {
-	buf *bytes.Buffer
+// argAt returns args[i], or undefined when fewer arguments were passed.
+func argAt(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
 }
 
-func (o *outputCapturer) Write(p []byte) (n int, err error) {
-	return o.buf.Write(p)
+// errorObject builds the {error} object used to reject invalid calls.
+func errorObject(msg string) js.Value {
+	errorResult := js.Global().Get("Object").New()
+	errorResult.Set("error", msg)
+	return errorResult
 }
 
-func executeGoCodeWrapper(this js.Value, args []js.Value) interface{} {
+// newPromise returns a JS Promise settled by fn, which runs on its own
+// goroutine so that it may block.
+func newPromise(fn func(resolve, reject js.Value)) js.Value {
 	handler := js.FuncOf(func(this js.Value, pArgs []js.Value) interface{} {
 		resolve := pArgs[0]
 		reject := pArgs[1]
 
-		go func() {
-			if len(args) == 0 || args[0].Type() != js.TypeString {
-				errorResult := js.Global().Get("Object").New()
-				errorResult.Set("error", "Invalid or missing code argument")
-				reject.Invoke(errorResult)
-				return
-			}
+		go fn(resolve, reject)
 
-			code := args[0].String()
-			var outputBuf, errorBuf bytes.Buffer
-			output := &outputCapturer{buf: &outputBuf}
-			errorOut := &outputCapturer{buf: &errorBuf}
+		return nil
+	})
 
-			// Create interpreter with stdlib support
-			i := interp.New(interp.Options{
-				Stdout: output,
-				Stderr: errorOut,
-			})
-			i.Use(stdlib.Symbols)
+	return js.Global().Get("Promise").New(handler)
+}
+
+// executeGoCodeWrapper runs the code given as first argument, either a source
+// string or an object mapping file names to the sources of one package. The
+// returned Promise has a cancel method stopping the run.
+func executeGoCodeWrapper(this js.Value, args []js.Value) interface{} {
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		code, warning := preprocess(argAt(args, 0))
+		src, ok := parseSource(code)
+		if !ok {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
 
-			// Execute the code
-			_, err := i.Eval(code)
-			if err != nil {
-				errorBuf.WriteString(err.Error())
-			}
+		opts := parseRunOptions(argAt(args, 1))
+		r := acquireRunner(opts)
+		result := r.eval(ctx, src, opts.timeout)
+		addWarning(result, warning)
+		r.settle(result, resolve, reject)
+		releaseRunner(r)
+		warmSpareRunner()
+	})
+}
 
-			// Prepare result for JS
-			result := js.Global().Get("Object").New()
-			result.Set("output", outputBuf.String())
-			result.Set("error", errorBuf.String())
-			resolve.Invoke(result)
-		}()
+// executeGoCodeStreamingWrapper behaves like executeGoCodeWrapper but also
+// passes each chunk of stdout to the callback given as second argument, as
+// soon as it is written, along with the runId of the run. Options move to the
+// third argument; with streamMode "lines", chunks are held back until they
+// end a line, the rest being passed once the run ends. A callback that
+// is not a function is ignored and output is only buffered.
+func executeGoCodeStreamingWrapper(this js.Value, args []js.Value) interface{} {
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		code, warning := preprocess(argAt(args, 0))
+		src, ok := parseSource(code)
+		if !ok {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
 
-		return nil
+		opts := parseRunOptions(argAt(args, 2))
+		opts.stream = argAt(args, 1)
+		r := acquireRunner(opts)
+		result := r.eval(ctx, src, opts.timeout)
+		addWarning(result, warning)
+		r.settle(result, resolve, reject)
+		releaseRunner(r)
+		warmSpareRunner()
 	})
+}
 
-	return js.Global().Get("Promise").New(handler)
+// registerFunctions sets the JS globals making up the runner's API.
+func registerFunctions() {
+	js.Global().Set("executeGoCode", js.FuncOf(executeGoCodeWrapper))
+	js.Global().Set("executeGoCodeStreaming", js.FuncOf(executeGoCodeStreamingWrapper))
+	js.Global().Set("executeBatch", js.FuncOf(executeBatchWrapper))
+	js.Global().Set("executeLatest", js.FuncOf(executeLatestWrapper))
+	js.Global().Set("evalToJSON", js.FuncOf(evalToJSONWrapper))
+	js.Global().Set("compileGoCode", js.FuncOf(compileGoCodeWrapper))
+	js.Global().Set("executeCompiled", js.FuncOf(executeCompiledWrapper))
+	js.Global().Set("disposeCompiled", js.FuncOf(disposeCompiledWrapper))
+	js.Global().Set("createSession", js.FuncOf(createSessionWrapper))
+	js.Global().Set("createPooledSession", js.FuncOf(createPooledSessionWrapper))
+	js.Global().Set("evalPooledSession", js.FuncOf(evalPooledSessionWrapper))
+	js.Global().Set("destroyPooledSession", js.FuncOf(destroyPooledSessionWrapper))
+	js.Global().Set("callFunction", js.FuncOf(callFunctionWrapper))
+	js.Global().Set("runTests", js.FuncOf(runTestsWrapper))
+	js.Global().Set("formatGoCode", js.FuncOf(formatGoCodeWrapper))
+	js.Global().Set("checkGoCode", js.FuncOf(checkGoCodeWrapper))
+	js.Global().Set("astJSON", js.FuncOf(astJSONWrapper))
+	js.Global().Set("listImports", js.FuncOf(listImportsWrapper))
+	js.Global().Set("complete", js.FuncOf(completeWrapper))
+	js.Global().Set("runnerInfo", js.FuncOf(runnerInfoWrapper))
+	js.Global().Set("listPackages", js.FuncOf(listPackagesWrapper))
+	js.Global().Set("registerSymbol", js.FuncOf(registerSymbolWrapper))
+	js.Global().Set("setPreprocessor", js.FuncOf(setPreprocessorWrapper))
+	js.Global().Set("resetRunner", js.FuncOf(resetRunnerWrapper))
+}
+
+// resetRunnerWrapper drops the cached interpreters, the spare runner and
+// those of compiled programs, whose handles become invalid, then registers
+// the API functions anew and warms a fresh spare runner, as on startup.
+// Sessions keep their own runners until closed, and registered symbols stay
+// registered.
+func resetRunnerWrapper(this js.Value, args []js.Value) interface{} {
+	select {
+	case <-spareRunner:
+	default:
+	}
+	compiledMu.Lock()
+	compiledPrograms = map[int]*compiled{}
+	compiledMu.Unlock()
+	runtime.GC()
+
+	registerFunctions()
+	warmSpareRunner()
+	return true
 }
 
 func main() {
 	fmt.Println("Go WebAssembly runner initialized")
-	js.Global().Set("executeGoCode", js.FuncOf(executeGoCodeWrapper))
+	registerFunctions()
+	// Let clients await goRunnerReady instead of polling for the functions
+	js.Global().Set("goRunnerReady", js.Global().Get("Promise").Call("resolve", true))
+	warmSpareRunner()
 	select {}
 }
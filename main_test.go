@@ -0,0 +1,26 @@
+package main
+
+import (
+	"syscall/js"
+	"testing"
+)
+
+// The tests run under GOOS=js GOARCH=wasm, with go_js_wasm_exec from the
+// Go distribution's lib/wasm directory on PATH:
+//
+//	GOOS=js GOARCH=wasm go test .
+
+// executeGo calls executeGoCode with code and opts, either of which may be
+// nil, and returns the object its Promise resolves to.
+func executeGo(t *testing.T, code interface{}, opts map[string]interface{}) js.Value {
+	t.Helper()
+	args := []js.Value{js.ValueOf(code)}
+	if opts != nil {
+		args = append(args, js.ValueOf(opts))
+	}
+	result := awaitValue(executeGoCodeWrapper(js.Undefined(), args).(js.Value))
+	if result.Type() != js.TypeObject {
+		t.Fatalf("executeGoCode rejected %q", code)
+	}
+	return result
+}
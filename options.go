@@ -0,0 +1,266 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+// runOptions holds the per-run settings passed to executeGoCode as its
+// optional second argument. For backward compatibility that argument may
+// also be a bare number, taken as the timeout in milliseconds.
+type runOptions struct {
+	timeout time.Duration
+	// stdin is given either as a string or as a function prompting for
+	// each line; see promptReader
+	stdin io.Reader
+	// args are the command-line arguments following the program name in os.Args
+	args []string
+	// env holds "key=value" entries making up the interpreter's environment
+	env []string
+	// allowedImports, when set, lists the only packages code may import,
+	// and deniedImports packages it may never import
+	allowedImports, deniedImports map[string]bool
+	// safeMode leaves out the packages and symbols giving access to memory,
+	// the host filesystem or the process; see safeModePackages. yaegi's own
+	// unrestricted mode, giving code the host's os.Args, environment and
+	// os.Exit, is never enabled.
+	safeMode bool
+	// strictImports rejects programs with unused imports, but for blank and
+	// dot imports
+	strictImports bool
+	// disabledPackages are standard library packages left out of the
+	// interpreter, reported as not available
+	disabledPackages map[string]bool
+	// autoImports are packages imported for the code, unless it imports
+	// them itself
+	autoImports []string
+	// randSeed, when set, seeds the top-level math/rand functions
+	randSeed *int64
+	// now, when set, is the instant time.Now is frozen at
+	now *time.Time
+	// files, when set, is the in-memory filesystem os file functions read
+	files map[string]string
+	// httpMocks, when set, are the responses net/http serves by URL; see
+	// runner.mockHTTP
+	httpMocks map[string]httpMock
+	// embedFiles maps the file names of //go:embed directives to their
+	// contents
+	embedFiles map[string]string
+	// transcript requests the interleaved log of stdout and stderr writes
+	transcript bool
+	// structuredErrors makes the error result field an object, and moves
+	// the error text to errorText
+	structuredErrors bool
+	// rejectOnError makes the Promise of a failed run reject rather than
+	// resolve; see runner.settle
+	rejectOnError bool
+	// stripAnsi removes ANSI escape sequences from the output, error and logs
+	// result fields; streamed chunks and the transcript keep them
+	stripAnsi bool
+	// sourceWithErrors requests the sourceWithErrors result field, the
+	// source annotated with the errors it failed to compile with
+	sourceWithErrors bool
+	// allErrors requests the diagnostics result field, listing all the
+	// errors code failed to compile with that can be told
+	allErrors bool
+	// lenientUnused requests the notes result field, listing the unused
+	// imports and variables Go would reject the code for, but which the
+	// interpreter runs anyway
+	lenientUnused bool
+	// fmtOutput requests the fmtOutput result field, the part of stdout
+	// written through fmt; see fmtOutputNote
+	fmtOutput bool
+	// concurrencyNote requests the note on the single-threaded scheduling of
+	// goroutines for code using them
+	concurrencyNote bool
+	// outputEncoding is how the output result field encodes stdout: "utf8",
+	// the default, or "base64", keeping bytes that are not valid UTF-8 along
+	// with any ANSI escapes
+	outputEncoding string
+	// maxMemory, when positive, is the heap growth in bytes after which a
+	// run is aborted
+	maxMemory int
+	// normalizeOutput requests the normalizedOutput result field, stdout
+	// with line endings and trailing whitespace normalized, and its final
+	// newline removed if stripFinalNewline
+	normalizeOutput, stripFinalNewline bool
+	// maxGoroutines, when positive, is the number of goroutines started by
+	// the code after which a run is aborted
+	maxGoroutines int
+	// maxOutput caps the bytes kept from each of stdout and stderr; a run
+	// writing more is stopped with stopOnOutputLimit, and goes on with its
+	// output dropped otherwise
+	maxOutput         int
+	stopOnOutputLimit bool
+	// maxSteps, when positive, is the number of interpreter steps after
+	// which a run is aborted
+	maxSteps int
+	// slowdown, when above 1, is how many times slower a run is made, to
+	// feel the cost of inefficient code; see executeSteps
+	slowdown float64
+	// signal, when an AbortSignal, cancels the run once it aborts
+	signal js.Value
+	// toJSON requests the json result field; see evalToJSON
+	toJSON bool
+	// stream receives stdout chunks as they are written; see
+	// executeGoCodeStreaming. With streamLines, set by the streamMode
+	// option being "lines" rather than "raw", the default, chunks are
+	// complete lines but for the last one
+	stream      js.Value
+	streamLines bool
+	// audit, when set by the audit option, requests the audit result field:
+	// "source" for a true option, or "hash" to only hash the source; see
+	// auditRecord
+	audit string
+	// lateOutputGrace is how long a run waits for the goroutines still
+	// running once main returns, if at all; those still running after it
+	// are abandoned, and what they print after that is lost. See
+	// awaitLateOutput
+	lateOutputGrace time.Duration
+}
+
+// defaultRunOptions returns the options used when executeGoCode is called
+// with none.
+func defaultRunOptions() runOptions {
+	return runOptions{timeout: defaultTimeout, maxOutput: defaultMaxOutput, outputEncoding: "utf8", lateOutputGrace: defaultLateOutputGrace}
+}
+
+func parseRunOptions(v js.Value) runOptions {
+	opts := defaultRunOptions()
+
+	switch v.Type() {
+	case js.TypeNumber:
+		opts.timeout = parseTimeout(v, opts.timeout)
+	case js.TypeObject:
+		opts.timeout = parseTimeout(v.Get("timeout"), opts.timeout)
+		switch stdin := v.Get("stdin"); stdin.Type() {
+		case js.TypeString:
+			opts.stdin = strings.NewReader(stdin.String())
+		case js.TypeFunction:
+			opts.stdin = newPromptReader(stdin)
+		}
+		opts.args = stringSlice(v.Get("args"))
+		for key, value := range stringMap(v.Get("env")) {
+			opts.env = append(opts.env, key+"="+value)
+		}
+		opts.allowedImports = stringSet(v.Get("allowedImports"))
+		opts.deniedImports = stringSet(v.Get("deniedImports"))
+		opts.disabledPackages = stringSet(v.Get("disabledPackages"))
+		opts.strictImports = v.Get("strictImports").Truthy()
+		opts.safeMode = v.Get("safeMode").Truthy()
+		opts.autoImports = stringSlice(v.Get("autoImport"))
+		if seed := v.Get("randSeed"); seed.Type() == js.TypeNumber {
+			n := int64(seed.Float())
+			opts.randSeed = &n
+		}
+		if now := v.Get("now"); now.Type() == js.TypeNumber {
+			// A Unix timestamp in seconds, possibly fractional
+			t := time.Unix(0, int64(now.Float()*float64(time.Second)))
+			opts.now = &t
+		}
+		opts.signal = v.Get("signal")
+		opts.files = stringMap(v.Get("fs"))
+		opts.embedFiles = stringMap(v.Get("embedFiles"))
+		opts.httpMocks = parseHTTPMocks(v.Get("httpMock"))
+		opts.transcript = v.Get("transcript").Truthy()
+		opts.structuredErrors = v.Get("structuredErrors").Truthy()
+		opts.stripAnsi = v.Get("stripAnsi").Truthy()
+		opts.rejectOnError = v.Get("rejectOnError").Truthy()
+		// Either a boolean or a {stripFinalNewline} object
+		if norm := v.Get("normalizeOutput"); norm.Truthy() {
+			opts.normalizeOutput = true
+			opts.stripFinalNewline = norm.Type() == js.TypeObject && norm.Get("stripFinalNewline").Truthy()
+		}
+		opts.concurrencyNote = v.Get("concurrencyNote").Truthy()
+		opts.sourceWithErrors = v.Get("sourceWithErrors").Truthy()
+		opts.allErrors = v.Get("allErrors").Truthy()
+		opts.lenientUnused = v.Get("lenientUnused").Truthy()
+		opts.fmtOutput = v.Get("fmtOutput").Truthy()
+		if audit := v.Get("audit"); audit.Type() == js.TypeString && audit.String() == "hash" {
+			opts.audit = "hash"
+		} else if audit.Truthy() {
+			opts.audit = "source"
+		}
+		if mode := v.Get("streamMode"); mode.Type() == js.TypeString && mode.String() == "lines" {
+			opts.streamLines = true
+		}
+		if enc := v.Get("outputEncoding"); enc.Type() == js.TypeString && enc.String() == "base64" {
+			opts.outputEncoding = "base64"
+		}
+		if limit := v.Get("maxOutputBytes"); limit.Type() == js.TypeNumber && limit.Int() > 0 {
+			opts.maxOutput = limit.Int()
+		}
+		opts.stopOnOutputLimit = v.Get("stopOnOutputLimit").Truthy()
+		if limit := v.Get("maxMemoryBytes"); limit.Type() == js.TypeNumber && limit.Float() > 0 {
+			opts.maxMemory = int(limit.Float())
+		}
+		if limit := v.Get("maxGoroutines"); limit.Type() == js.TypeNumber && limit.Int() > 0 {
+			opts.maxGoroutines = limit.Int()
+		}
+		if steps := v.Get("maxSteps"); steps.Type() == js.TypeNumber && steps.Int() > 0 {
+			opts.maxSteps = steps.Int()
+		}
+		// Unlike the timeout, the grace period may be 0, to not wait at all
+		if grace := v.Get("lateOutputGrace"); grace.Type() == js.TypeNumber && grace.Float() >= 0 {
+			opts.lateOutputGrace = time.Duration(grace.Float() * float64(time.Millisecond))
+		}
+		if factor := v.Get("slowdownFactor"); factor.Type() == js.TypeNumber && factor.Float() > 1 {
+			opts.slowdown = factor.Float()
+		}
+	}
+
+	return opts
+}
+
+// parseTimeout converts a JS millisecond count, falling back to def when v is
+// not a positive number.
+func parseTimeout(v js.Value, def time.Duration) time.Duration {
+	if v.Type() != js.TypeNumber || v.Float() <= 0 {
+		return def
+	}
+	return time.Duration(v.Float() * float64(time.Millisecond))
+}
+
+// stringSlice converts a JS array to a slice of the string values of its
+// elements, or nil when v is not an array.
+func stringSlice(v js.Value) []string {
+	if !js.Global().Get("Array").Call("isArray", v).Bool() {
+		return nil
+	}
+	s := make([]string, v.Length())
+	for i := range s {
+		s[i] = js.Global().Get("String").Invoke(v.Index(i)).String()
+	}
+	return s
+}
+
+// stringMap converts the own enumerable properties of a JS object to a map of
+// their string values, or nil when v is not an object.
+func stringMap(v js.Value) map[string]string {
+	if v.Type() != js.TypeObject {
+		return nil
+	}
+	entries := js.Global().Get("Object").Call("entries", v)
+	m := make(map[string]string, entries.Length())
+	for i := 0; i < entries.Length(); i++ {
+		e := entries.Index(i)
+		m[e.Index(0).String()] = js.Global().Get("String").Invoke(e.Index(1)).String()
+	}
+	return m
+}
+
+// stringSet converts a JS array to a set of its string values, or nil when v
+// is not an array.
+func stringSet(v js.Value) map[string]bool {
+	s := stringSlice(v)
+	if s == nil {
+		return nil
+	}
+	m := make(map[string]bool, len(s))
+	for _, e := range s {
+		m[e] = true
+	}
+	return m
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// panicFrame matches the "file:line:col: panic: pkg.func(...)" lines yaegi
+// writes to stderr for each interpreted frame a panic unwinds, innermost
+// first. The file is omitted for code not given as files.
+var panicFrame = regexp.MustCompile(`(?m)^(?:([^\s:]+):)?(\d+):(\d+): panic: (.+)\(\.\.\.\)$`)
+
+// panicTrace returns the interpreted frames unwound by the last panic, as
+// {function, file, line, column} objects, innermost first.
+func (r *runner) panicTrace() []interface{} {
+	var frames []interface{}
+	for _, m := range panicFrame.FindAllStringSubmatch(r.stderr.String(), -1) {
+		line, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		line, column = r.unshift(line, column)
+		frames = append(frames, map[string]interface{}{
+			"function": m[4],
+			"file":     m[1],
+			"line":     line,
+			"column":   column,
+		})
+	}
+	return frames
+}
+
+// stackOffset matches the " +0x1f" program counter offsets in stack traces.
+var stackOffset = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+
+// formatPanic renders a recovered panic value with a cleaned-up stack, as
+// produced by debug.Stack, for display alongside other runtime errors.
+func formatPanic(value interface{}, stack []byte) string {
+	return fmt.Sprintf("panic: %v\n\n%s", value, cleanStack(stack))
+}
+
+// cleanStack drops the goroutine header, the frames of the runtime itself,
+// of reflection and of yaegi running the code, argument words and program
+// counter offsets from a stack trace.
+func cleanStack(stack []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "goroutine ") {
+		lines = lines[1:]
+	}
+
+	var b strings.Builder
+	// Frames come in pairs: the function, then its tab-indented location
+	for i := 0; i+1 < len(lines); i += 2 {
+		fn, loc := lines[i], lines[i+1]
+		if hiddenFrame(fn) {
+			continue
+		}
+		if i := strings.LastIndex(fn, "("); i > 0 && strings.HasSuffix(fn, ")") && !strings.HasSuffix(fn, "()") {
+			fn = fn[:i] + "(...)"
+		}
+		b.WriteString(fn)
+		b.WriteString("\n")
+		b.WriteString(stackOffset.ReplaceAllString(loc, ""))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// hiddenFramePrefixes are those of the functions of the frames cleanStack
+// drops.
+var hiddenFramePrefixes = []string{"runtime.", "runtime/debug.", "panic(", "reflect.", "github.com/traefik/yaegi/"}
+
+func hiddenFrame(fn string) bool {
+	for _, prefix := range hiddenFramePrefixes {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
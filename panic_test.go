@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestCleanStack(t *testing.T) {
+	stack := `goroutine 7 [running]:
+runtime/debug.Stack()
+	/go/src/runtime/debug/stack.go:26 +0x5e
+panic({0x1234, 0x5678})
+	/go/src/runtime/panic.go:770 +0x132
+reflect.Value.call({0x1, 0x2, 0x3}, {0x4, 0x4}, {0x5, 0x1, 0x1})
+	/go/src/reflect/value.go:596 +0xce5
+github.com/traefik/yaegi/interp.callBin.func10(0x6)
+	/go/pkg/mod/github.com/traefik/yaegi@v0.16.1/interp/run.go:1592 +0x2b
+main.(*runner).call.func2()
+	/src/call.go:61 +0x7a
+`
+	want := "main.(*runner).call.func2()\n\t/src/call.go:61\n"
+	if got := cleanStack([]byte(stack)); got != want {
+		t.Errorf("cleanStack = %q, want %q", got, want)
+	}
+}
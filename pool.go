@@ -0,0 +1,107 @@
+package main
+
+import "syscall/js"
+
+// Building an interpreter is dominated by i.Use(stdlib.Symbols), which fills
+// the interpreter's own symbol tables and compiles yaegi's generic stdlib
+// sources. None of that can be shared between interpreters, so instead a
+// spare one is built ahead of time, while the runner is idle, and handed to
+// the next run.
+//
+// Runners whose runs ended cleanly are reused as well: releaseRunner hands
+// one back, to become the spare once resetGlobals has brought its
+// interpreter back to the state it was built in, which costs a fraction of
+// building another. Like building, resetting waits for warmSpareRunner.
+// yaegi has no API for it, so resetGlobals restores unexported fields of
+// the Interpreter; with a version of yaegi lacking them, runners are not
+// reused.
+//
+// Isolation: a spare has either never evaluated any code or had its globals
+// reset since, and each is handed out at most once, so every run still
+// starts from fresh interpreter globals. A run whose goroutines may still be
+// running, as after a timeout, never gives its runner back, since they keep
+// referring to the globals reset. As before, state held by the compiled-in
+// stdlib packages themselves (such as the math/rand global source) is shared
+// by the whole process.
+//
+// Each runner captures its output with writers of its own, so runs that
+// overlap cannot see each other's output. Only sessions hand one runner to
+// several calls, and they serialize them.
+
+// spareRunner holds at most one pre-warmed runner, and releasedRunner one
+// handed back by releaseRunner, not reset yet.
+var (
+	spareRunner    = make(chan *runner, 1)
+	releasedRunner = make(chan *runner, 1)
+)
+
+// reusable reports whether opts need not be fixed at interpreter creation,
+// so that a runner built for others may serve them.
+func reusable(opts runOptions) bool {
+	return opts.args == nil && opts.env == nil && opts.disabledPackages == nil && !opts.safeMode
+}
+
+// acquireRunner returns a runner configured for opts, taking the spare one
+// when opts are reusable.
+func acquireRunner(opts runOptions) *runner {
+	if reusable(opts) {
+		select {
+		case r := <-spareRunner:
+			r.configure(opts)
+			return r
+		default:
+		}
+	}
+	return newRunner(opts)
+}
+
+// releaseRunner hands r back, once its run is over, for warmSpareRunner to
+// reset and make the spare, unless there is one already. Runners built for
+// options that are not reusable are dropped, as are those of runs that may
+// have left goroutines running: stopped ones, and those that exited or leaked
+// some.
+func releaseRunner(r *runner) {
+	if r.globals == nil || !reusable(r.opts) || r.leaked > 0 || r.exited || r.exceeded != nil {
+		return
+	}
+	switch r.failure {
+	case errorKindTimeout, errorKindDeadlock, errorKindCancelled, errorKindBudget:
+		return
+	}
+	if len(spareRunner) == 0 {
+		select {
+		case releasedRunner <- r:
+		default:
+		}
+	}
+}
+
+// warmSpareRunner schedules building a spare runner, or resetting the one
+// released, unless one is already available. The work is deferred to a JS
+// task rather than a goroutine, since the wasm scheduler would otherwise run
+// it before the pending Promise callbacks get a chance to.
+func warmSpareRunner() {
+	if len(spareRunner) == 0 {
+		js.Global().Call("setTimeout", warmSpareRunnerFunc, 0)
+	}
+}
+
+var warmSpareRunnerFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	go func() {
+		if len(spareRunner) > 0 {
+			return
+		}
+		var r *runner
+		select {
+		case r = <-releasedRunner:
+			r.resetGlobals()
+		default:
+			r = newRunner(defaultRunOptions())
+		}
+		select {
+		case spareRunner <- r:
+		default:
+		}
+	}()
+	return nil
+})
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"syscall/js"
+	"testing"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+func TestResetGlobals(t *testing.T) {
+	r := newRunner(defaultRunOptions())
+	eval := func(code string, opts map[string]interface{}) (output, errText string) {
+		t.Helper()
+		r.resetGlobals()
+		r.configure(parseRunOptions(js.ValueOf(opts)))
+		result := r.eval(context.Background(), wrapSnippet(code), time.Second)
+		return result.Get("output").String(), result.Get("error").String()
+	}
+
+	program := `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type T int
+
+var counter = 41
+
+func helper() T { return 1 }
+
+func main() {
+	counter++
+	fmt.Println(counter, strings.ToUpper("a"), helper())
+}`
+	for i := 0; i < 2; i++ {
+		if out, errText := eval(program, nil); out != "42 A 1\n" || errText != "" {
+			t.Errorf("run %d: output %q, error %q", i, out, errText)
+		}
+	}
+
+	for _, code := range []string{`x := 5`, `import "strings"`, `func f() int { return 1 }`} {
+		if _, errText := eval(code, nil); errText != "" {
+			t.Fatalf("%s: %s", code, errText)
+		}
+	}
+	for _, code := range []string{`x`, `strings.ToUpper("a")`, `f()`} {
+		if _, errText := eval(code, nil); !strings.Contains(errText, "undefined") {
+			t.Errorf("%s after reset: error %q, want undefined", code, errText)
+		}
+	}
+
+	typeOfClient := `import ("fmt"; "net/http"); fmt.Printf("%T", http.DefaultClient)`
+	mocks := map[string]interface{}{"httpMock": map[string]interface{}{"https://example.com/": "mocked"}}
+	mocked, _ := eval(typeOfClient, mocks)
+	if out, errText := eval(typeOfClient, nil); out != "*http.Client" || out == mocked {
+		t.Errorf("DefaultClient after a mocked run is %q (error %q), want *http.Client", out, errText)
+	}
+
+	dirty := `import ("flag"; "log"; "os")
+log.SetFlags(0); log.SetPrefix("P "); os.Setenv("DIRTY", "1"); os.Args = nil; os.Stdout = nil
+flag.CommandLine.Bool("dirty", false, "")`
+	if _, errText := eval(dirty, nil); errText != "" {
+		t.Fatalf("dirty run: %s", errText)
+	}
+	probe := `import ("flag"; "fmt"; "log"; "os")
+fmt.Println(log.Flags(), log.Prefix() == "", os.Getenv("DIRTY") == "", os.Args, flag.CommandLine.Lookup("dirty") == nil)`
+	want := fmt.Sprintln(log.LstdFlags, true, true, []string{programName}, true)
+	if out, errText := eval(probe, nil); out != want {
+		t.Errorf("after a run changing the state of os, log and flag: output %q (error %q), want %q", out, errText, want)
+	}
+}
+
+// TestGlobalsFields pins the fields of the Interpreter, so that upgrading
+// yaegi fails it when they change, and what resetGlobals restores is
+// reviewed.
+func TestGlobalsFields(t *testing.T) {
+	if !globalsFieldsFound {
+		t.Fatal("the Interpreter lacks fields resetGlobals restores")
+	}
+	want := []string{"binPkg", "cancelChan", "debugger", "done", "frame", "fset", "generic", "hooks", "id", "mapTypes", "mutex", "name", "nindex", "opt", "pkgNames", "rdir", "roots", "scopes", "srcPkg", "universe"}
+	typ := reflect.TypeOf((*interp.Interpreter)(nil)).Elem()
+	var got []string
+	for i := 0; i < typ.NumField(); i++ {
+		got = append(got, typ.Field(i).Name)
+	}
+	sort.Strings(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("Interpreter fields = %v, want %v", got, want)
+	}
+}
+
+func BenchmarkNewRunner(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		newRunner(defaultRunOptions()).eval(context.Background(), source{code: "1 + 1"}, time.Second)
+	}
+}
+
+func BenchmarkResetGlobals(b *testing.B) {
+	r := newRunner(defaultRunOptions())
+	for i := 0; i < b.N; i++ {
+		r.eval(context.Background(), source{code: "1 + 1"}, time.Second)
+		r.resetGlobals()
+	}
+}
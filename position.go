@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"go/scanner"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// errorPos matches the "file.go:line:col: " or "line:col: " prefix yaegi
+	// puts on compile errors.
+	errorPos = regexp.MustCompile(`^(?:[^\s:]+:)?(\d+):(\d+): `)
+	// panicPos matches the "file.go:line:col: panic" or "line:col: panic" line
+	// yaegi writes to stderr when interpreted code panics.
+	panicPos = regexp.MustCompile(`(?m)^(?:[^\s:]+:)?(\d+):(\d+): panic`)
+)
+
+// errorPosition extracts the source position carried by err, or 0, 0 when it
+// has none.
+func errorPosition(err error) (line, column int) {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return list[0].Pos.Line, list[0].Pos.Column
+	}
+	return matchPosition(errorPos, err.Error())
+}
+
+// unshift maps a position reported by yaegi back to the source of the run.
+func (r *runner) unshift(line, column int) (int, int) {
+	return unshift(r.shift, line, column)
+}
+
+// posShift is how positions move once a source is wrapped to compile: by
+// first columns on its first line, and by inserted columns from column on
+// line, where wrapSnippet opens main, if it did.
+type posShift struct {
+	first                  int
+	line, column, inserted int
+}
+
+// unshift maps a position in a source shifted by shift back to the source.
+// Positions within the inserted text map to where it was inserted.
+func unshift(shift posShift, line, column int) (int, int) {
+	if line == 1 && column > shift.first {
+		column -= shift.first
+	}
+	if line == shift.line && column >= shift.column {
+		column = max(column-shift.inserted, shift.column)
+	}
+	return line, column
+}
+
+func matchPosition(re *regexp.Regexp, s string) (line, column int) {
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0
+	}
+	line, _ = strconv.Atoi(m[1])
+	column, _ = strconv.Atoi(m[2])
+	return line, column
+}
+
+// unshiftErrors maps the positions of the scanner.ErrorList of err, if any,
+// back to a source shifted by shift.
+func unshiftErrors(err error, shift posShift) error {
+	var list scanner.ErrorList
+	if errors.As(err, &list) {
+		for _, e := range list {
+			e.Pos.Line, e.Pos.Column = unshift(shift, e.Pos.Line, e.Pos.Column)
+		}
+	}
+	return err
+}
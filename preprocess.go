@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"syscall/js"
+)
+
+// The JS function set by setPreprocessor, or undefined.
+var (
+	preprocessorMu sync.Mutex
+	preprocessor   = js.Undefined()
+)
+
+// setPreprocessorWrapper sets the function given as first argument as the
+// preprocessor of the code of executeGoCode and its variants, or removes it
+// when given null or undefined. It returns an {error} object for any other
+// argument.
+func setPreprocessorWrapper(this js.Value, args []js.Value) interface{} {
+	fn := argAt(args, 0)
+	switch fn.Type() {
+	case js.TypeFunction:
+	case js.TypeNull, js.TypeUndefined:
+		fn = js.Undefined()
+	default:
+		return errorObject("Invalid preprocessor argument")
+	}
+	preprocessorMu.Lock()
+	preprocessor = fn
+	preprocessorMu.Unlock()
+	return nil
+}
+
+// preprocess returns the code argument v of a run after passing it through
+// the preprocessor, if set: a source string is replaced as a whole, and each
+// file of an object by its own call, with the file name as second argument.
+// The preprocessor may return a Promise. When it throws or returns anything
+// but a string, v is returned unchanged along with a warning for the result.
+func preprocess(v js.Value) (out js.Value, warning string) {
+	preprocessorMu.Lock()
+	fn := preprocessor
+	preprocessorMu.Unlock()
+	if fn.IsUndefined() {
+		return v, ""
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			out, warning = v, fmt.Sprintf("the preprocessor failed, so the original source was run: %v", p)
+		}
+	}()
+	call := func(code js.Value, name interface{}) js.Value {
+		res := awaitValue(fn.Invoke(code, name))
+		if res.Type() != js.TypeString {
+			panic(fmt.Sprintf("it returned %s rather than a string", res.Type()))
+		}
+		return res
+	}
+
+	switch {
+	case v.Type() == js.TypeString:
+		return call(v, nil), ""
+	case v.Type() == js.TypeObject && !isArray(v):
+		files := js.Global().Get("Object").New()
+		entries := js.Global().Get("Object").Call("entries", v)
+		for i := 0; i < entries.Length(); i++ {
+			name := entries.Index(i).Index(0)
+			files.Set(name.String(), call(entries.Index(i).Index(1), name))
+		}
+		return files, ""
+	}
+	return v, ""
+}
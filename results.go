@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"strings"
+	"syscall/js"
+)
+
+// expandResults rewrites src, when a fragment ending with a call of a
+// function returning several results, so that it evaluates to all of them as
+// a []interface{}, and returns their types. Otherwise src is returned as is,
+// with no types. Only calls of a named function or method are considered, as
+// telling the number of results takes evaluating the function itself.
+func (r *runner) expandResults(src source) (_ source, types []reflect.Type) {
+	if src.isProgram() {
+		return src, nil
+	}
+	prefix := wrapFragment(src.code)
+	f := parseFragment(src.code)
+	if f == nil || len(f.Decls) != 1 || !isFragmentMain(f.Decls[0]) {
+		return src, nil
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body.List
+	if len(body) == 0 {
+		return src, nil
+	}
+	stmt, ok := body[len(body)-1].(*ast.ExprStmt)
+	if !ok {
+		return src, nil
+	}
+	call, ok := stmt.X.(*ast.CallExpr)
+	if !ok {
+		return src, nil
+	}
+	switch call.Fun.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+	default:
+		return src, nil
+	}
+
+	offset := func(n ast.Node) (int, int) { return int(n.Pos()) - 1 - len(prefix), int(n.End()) - 1 - len(prefix) }
+	start, end := offset(call.Fun)
+	defer func() {
+		// Evaluating the function may fail in ways yaegi reports by panicking
+		if recover() != nil {
+			types = nil
+		}
+	}()
+	fn, err := r.interp.Eval(src.code[start:end])
+	if err != nil || fn.Kind() != reflect.Func || fn.Type().NumOut() < 2 {
+		return src, nil
+	}
+
+	// A function literal called in place would be simpler, but yaegi loses
+	// the value of such calls. The variables stay in a session's scope.
+	names := make([]string, fn.Type().NumOut())
+	for i := range names {
+		names[i] = fmt.Sprintf("_result%d_", i)
+		types = append(types, fn.Type().Out(i))
+	}
+	start, end = offset(call)
+	list := strings.Join(names, ", ")
+	code := src.code[:start] + list + " := " + src.code[start:end] + "; []interface{}{" + list + "}" + src.code[end:]
+	return source{code: code}, types
+}
+
+// setResults sets the results, resultTypes and, when the last result is an
+// error, resultError fields of result, from value, a fragment's results
+// expanded by expandResults. The result and resultType fields keep the first.
+func setResults(result js.Value, value reflect.Value, types []reflect.Type) {
+	values, ok := value.Interface().([]interface{})
+	if !ok || len(values) != len(types) {
+		return
+	}
+	results := make([]interface{}, len(values))
+	names := make([]interface{}, len(types))
+	for i, v := range values {
+		results[i] = fmt.Sprintf("%v", v)
+		names[i] = types[i].String()
+	}
+	result.Set("result", results[0])
+	result.Set("resultType", names[0])
+	result.Set("results", results)
+	result.Set("resultTypes", names)
+	if types[len(types)-1] == errorType {
+		if err, ok := values[len(values)-1].(error); ok && err != nil {
+			result.Set("resultError", err.Error())
+		} else {
+			result.Set("resultError", js.Null())
+		}
+	}
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+const (
+	// defaultTimeout bounds a run when executeGoCode is called without a timeout.
+	defaultTimeout = 5000 * time.Millisecond
+	// defaultMaxOutput caps each of stdout and stderr when no limit is given.
+	defaultMaxOutput = 1 << 20
+	// programName is os.Args[0] as seen by interpreted code.
+	programName = "main"
+)
+
+// Values of the errorKind result field, naming the phase that failed.
+const (
+	errorKindCompile = "compile"
+	errorKindRuntime = "runtime"
+)
+
+// Finer kinds of failures, as reported in structured errors along with
+// errorKindCompile and errorKindRuntime.
+const (
+	errorKindPanic      = "panic"
+	errorKindTimeout    = "timeout"
+	errorKindCancelled  = "cancelled"
+	errorKindDeadlock   = "deadlock"
+	errorKindBudget     = "budget"
+	errorKindMemory     = "memory"
+	errorKindGoroutines = "goroutines"
+	errorKindOutput     = "output"
+)
+
+// runner couples an interpreter with the capturers of its output. The
+// interpreter keeps its state across calls to eval.
+type runner struct {
+	opts           runOptions
+	interp         *interp.Interpreter
+	stdout, stderr *outputCapturer
+	// logOutput captures the output of the log package, which would
+	// otherwise go to stderr
+	logOutput  *outputCapturer
+	stdin      stdinReader
+	transcript transcript
+	// exited tells the last eval called os.Exit, with exitCode, and stop
+	// stops the eval in progress, if any
+	exited   bool
+	exitCode int
+	stop     context.CancelFunc
+	// resultTypes are the types of the results of the last eval, when
+	// expanded by expandResults
+	resultTypes []reflect.Type
+	// exceeded is the error of the limit watchLimits stopped the last eval
+	// for, if any, and peakHeap the largest heap observed during it
+	exceeded error
+	peakHeap uint64
+	// autoImported are the packages autoImport imported into the
+	// interpreter's scope
+	autoImported map[string]bool
+	// packages holds the sources of the user packages of the last program
+	packages packageFS
+	// osStdin is the os.Stdin of the code once it reads from r.stdin
+	osStdin io.Reader
+	// fmtOutput captures what fmt writes to stdout, with the fmtOutput
+	// option, and osStdout and osStderr are the os.Stdout and os.Stderr of
+	// the code
+	fmtOutput *outputCapturer
+	osStdout  *stdoutFile
+	osStderr  *stderrFile
+	// errorKind is the phase that failed during the last eval, if any, and
+	// errorLine and errorColumn where in the source it did, when known
+	errorKind              string
+	errorLine, errorColumn int
+	// failure is the finer kind of failure, with its message and, for
+	// panics, cleaned-up stack
+	failure, errorMessage, errorStack string
+	// started is when the last eval began, and elapsed its wall-clock
+	// duration
+	started time.Time
+	elapsed time.Duration
+	// blocked lists what the interpreted goroutines were waiting on when the
+	// last eval timed out, if that was all they did
+	blocked []string
+	// root is the id of the goroutine the run was spawned on
+	root string
+	// runID is the runId result field of the run, a random UUID
+	runID string
+	// shift is the columnShift of the source of the run
+	shift posShift
+	// goroutinesBefore is the number of goroutines when the run started
+	goroutinesBefore int
+	// leaked is the number of goroutines of the last eval still alive once
+	// it returned, and its grace period ended; those of a stopped eval are
+	// not counted
+	leaked int
+	// lateOutput is the stdout written during the grace period
+	lateOutput string
+	// globals is the state resetGlobals restores, for a runner built with
+	// reusable options
+	globals *globalsSnapshot
+	// callProg is the program execute runs, which calls calling
+	callProg *interp.Program
+	calling  func()
+}
+
+// stdinReader lets the reader behind an interpreter's stdin be chosen after
+// the interpreter is built. It reads the host's stdin when none is set.
+type stdinReader struct {
+	r io.Reader
+}
+
+func (s *stdinReader) Read(p []byte) (n int, err error) {
+	if s.r == nil {
+		return os.Stdin.Read(p)
+	}
+	return s.r.Read(p)
+}
+
+// setStdin makes stdin the reader of the code's stdin, when not nil. Code
+// compiled before keeps reading through r.stdin, so the reader may change
+// between executions of a compiled program.
+func (r *runner) setStdin(stdin io.Reader) {
+	r.stdin.r = stdin
+	if stdin != nil && r.osStdin == nil {
+		// yaegi only rewires os.Stdin for *os.File readers
+		r.osStdin = &r.stdin
+		r.use(interp.Exports{"os/os": {"Stdin": reflect.ValueOf(&r.osStdin).Elem()}})
+	}
+}
+
+// newRunner builds a runner for opts. Use acquireRunner instead, which may
+// hand out a pre-warmed one.
+func newRunner(opts runOptions) *runner {
+	r := &runner{}
+	r.stdout = &outputCapturer{name: "stdout"}
+	r.stderr = &outputCapturer{name: "stderr"}
+	r.logOutput = &outputCapturer{name: "log"}
+	r.fmtOutput = &outputCapturer{name: "fmt"}
+	for _, o := range []*outputCapturer{r.stdout, r.stderr, r.logOutput} {
+		o.full = r.stopOutput
+	}
+
+	// Create interpreter with stdlib support
+	r.interp = interp.New(interp.Options{
+		Stdin:  &r.stdin,
+		Stdout: fmtStdout{r},
+		Stderr: r.stderr,
+		// Never let the host's own arguments through
+		Args: append([]string{programName}, opts.args...),
+		// Outside unrestricted mode yaegi serves os.Getenv and friends from
+		// this per-interpreter copy, so the host environment is untouched
+		Env: opts.env,
+		// User packages are imported from r.packages, as GOPATH
+		SourcecodeFilesystem: &r.packages,
+		GoPath:               ".",
+	})
+	r.use(stdlibSymbols(opts))
+	// Channel operations only compile to cancellable ones once a call taking
+	// a context has run; without that, goroutines blocked on them would
+	// outlive a timed out run
+	r.interp.EvalWithContext(context.Background(), "")
+	r.useYield()
+	r.useCall()
+	r.resetLog()
+	r.useExit()
+	r.useStdFiles()
+	if reusable(opts) && globalsFieldsFound {
+		r.globals = r.snapshotGlobals()
+	}
+	r.configure(opts)
+
+	return r
+}
+
+// resetLog redirects the logger yaegi binds the log functions to, one of its
+// own, to r.logOutput, with the flags and prefix of a new one.
+func (r *runner) resetLog() {
+	symbols := r.interp.Symbols("log")["log"]
+	symbols["SetOutput"].Call([]reflect.Value{reflect.ValueOf(io.Writer(r.logOutput))})
+	symbols["SetFlags"].Call([]reflect.Value{reflect.ValueOf(log.LstdFlags)})
+	symbols["SetPrefix"].Call([]reflect.Value{reflect.ValueOf("")})
+}
+
+// configure applies the options that need not be fixed when the interpreter
+// is created.
+func (r *runner) configure(opts runOptions) {
+	r.opts = opts
+	r.stdout.stream, r.stdout.limit = opts.stream, opts.maxOutput
+	r.stdout.lines = opts.streamLines
+	r.stderr.limit = opts.maxOutput
+	r.logOutput.limit = opts.maxOutput
+	r.fmtOutput.limit = opts.maxOutput
+	for _, o := range []*outputCapturer{r.stdout, r.stderr, r.logOutput} {
+		o.log = nil
+		if opts.transcript {
+			o.log = &r.transcript
+		}
+	}
+	r.setStdin(opts.stdin)
+	if opts.randSeed != nil {
+		r.seedRand(*opts.randSeed)
+	}
+	if opts.now != nil {
+		r.freezeTime(*opts.now)
+	}
+	if opts.files != nil {
+		r.mountFiles(opts.files)
+	}
+	if opts.httpMocks != nil {
+		r.mockHTTP(opts.httpMocks)
+	}
+	r.useHostSymbols()
+}
+
+// eval runs src on the interpreter and returns the JS result object for it,
+// with output captured since the previous call only. Panics are reported in
+// the error field rather than escaping to the caller. Cancelling ctx, or
+// exceeding timeout, stops the interpreter.
+func (r *runner) eval(ctx context.Context, src source, timeout time.Duration) js.Value {
+	return r.run(ctx, src, nil, timeout)
+}
+
+// run is eval, executing prog, compiled from src beforehand, when not nil.
+func (r *runner) run(ctx context.Context, src source, prog *interp.Program, timeout time.Duration) (result js.Value) {
+	r.reset()
+	r.shift = src.columnShift()
+	start := time.Now()
+	r.started = start
+	defer func() {
+		if p := recover(); p != nil {
+			r.elapsed = time.Since(start)
+			r.failPanic(errorKindRuntime, p, debug.Stack())
+			result = r.result(src, reflect.Value{})
+		}
+	}()
+
+	ctx, release := withAbortSignal(ctx, r.opts.signal)
+	defer release()
+	if err := ctx.Err(); err != nil {
+		// Cancelled before it started, the code is not run at all
+		r.record(errorKindRuntime, err, timeout)
+		return r.result(src, reflect.Value{})
+	}
+
+	// Execute the code, stopping it if it outlives the timeout.
+	// The wasm scheduler never preempts, so the deadline only fires
+	// once the evaluating goroutine blocks or yields, as the code
+	// compiled is made to; see addYields.
+	// Compiling separately from executing tells which phase failed.
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r.stop = cancel
+	defer func() { r.stop = nil }()
+	// The heap is sampled when the run starts and ends, and in between while
+	// it is limited
+	r.heapAlloc()
+	if r.opts.maxMemory > 0 || r.opts.maxGoroutines > 0 {
+		watched := make(chan struct{})
+		defer close(watched)
+		go r.watchLimits(watched)
+	}
+	type evalResult struct {
+		value reflect.Value
+		err   error
+		kind  string
+	}
+	var res evalResult
+	done := r.spawn(func() {
+		res.kind = errorKindCompile
+		defer func() {
+			if p := recover(); p != nil {
+				res.err = interp.Panic{Value: p, Stack: debug.Stack()}
+			}
+		}()
+		if prog == nil {
+			compiled, types := r.expandResults(src)
+			r.resultTypes = types
+			var err error
+			if prog, err = r.compileSource(compiled); err != nil {
+				res.err = err
+				return
+			}
+		}
+		res.kind = errorKindRuntime
+		if r.stepping() {
+			res.value, res.err = r.executeSteps(runCtx, prog, r.opts.maxSteps, r.opts.slowdown)
+		} else {
+			res.value, res.err = r.interp.ExecuteWithContext(runCtx, prog)
+		}
+	})
+
+	// An abandoned run may still write to res once it is stopped
+	var ended evalResult
+	if err := r.await(ctx, cancel, done, timeout); err != nil {
+		ended = evalResult{err: err, kind: errorKindRuntime}
+	} else {
+		ended = res
+		switch {
+		case r.exited:
+			// Stopped by os.Exit rather than failing
+			ended.err = nil
+		case r.exceeded != nil:
+			ended = evalResult{err: r.exceeded, kind: errorKindRuntime}
+		}
+		// Count before the deferred cancel releases the blocked goroutines
+		r.leaked = leakedGoroutines(r.root)
+	}
+	r.elapsed = time.Since(start)
+	if ended.err == nil && !r.exited {
+		r.lateOutput = r.awaitLateOutput(ctx, r.stdout.Len())
+	}
+	r.heapAlloc()
+	r.record(ended.kind, ended.err, timeout)
+	r.stdout.flush()
+
+	return r.result(src, ended.value)
+}
+
+// spawn runs fn on a goroutine of its own, recorded as the root of the goroutines
+// of the run, and returns a channel closed once fn returns.
+func (r *runner) spawn(fn func()) <-chan struct{} {
+	done := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.root = goroutineID()
+		close(started)
+		fn()
+	}()
+	<-started
+	return done
+}
+
+// await waits for done to be closed and returns nil, unless ctx is done or
+// timeout elapses first. It then calls cancel, which must stop the
+// interpreter, and returns the context error. On timeout it also records
+// whether the interpreted goroutines were all blocked, as in a deadlock.
+func (r *runner) await(ctx context.Context, cancel context.CancelFunc, done <-chan struct{}, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	case <-timer.C:
+		// Look before cancelling, which wakes up goroutines blocked on
+		// interpreted channels
+		r.blocked = blockedStates(r.root)
+		cancel()
+		return context.DeadlineExceeded
+	}
+}
+
+// reset forgets the output and failure of the previous call, and gives the
+// next one its run id.
+func (r *runner) reset() {
+	r.runID = newRunID()
+	r.stdout.setRunID(r.runID)
+	r.stdout.reset()
+	r.stderr.reset()
+	r.logOutput.reset()
+	r.fmtOutput.reset()
+	r.transcript.reset()
+	r.errorKind = ""
+	r.errorLine, r.errorColumn = 0, 0
+	r.failure, r.errorMessage, r.errorStack = "", "", ""
+	r.blocked = nil
+	r.goroutinesBefore = runtime.NumGoroutine()
+	r.leaked, r.lateOutput = 0, ""
+	r.exited, r.exitCode = false, 0
+	r.resultTypes = nil
+	r.exceeded, r.peakHeap = nil, 0
+}
+
+// record classifies err, returned by the given phase of a call bounded by
+// timeout, and records it as the failure, if any.
+func (r *runner) record(phase string, err error, timeout time.Duration) {
+	var p interp.Panic
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded) && r.blocked != nil:
+		r.fail(errorKindRuntime, errorKindDeadlock, fmt.Sprintf("execution timed out after %dms: possible deadlock, all goroutines are blocked (%s)",
+			timeout.Milliseconds(), strings.Join(r.blocked, ", ")))
+	case errors.Is(err, context.DeadlineExceeded):
+		r.fail(errorKindRuntime, errorKindTimeout, fmt.Sprintf("execution timed out after %dms", timeout.Milliseconds()))
+	case errors.Is(err, context.Canceled):
+		r.fail(errorKindRuntime, errorKindCancelled, "execution cancelled")
+	case errors.Is(err, errStepBudget):
+		r.fail(errorKindRuntime, errorKindBudget, fmt.Sprintf("instruction budget exceeded after %d steps", r.opts.maxSteps))
+	case errors.Is(err, errMemoryLimit):
+		r.fail(errorKindRuntime, errorKindMemory, fmt.Sprintf("memory limit exceeded: the heap grew by more than %d bytes", r.opts.maxMemory))
+	case errors.Is(err, errGoroutineLimit):
+		r.fail(errorKindRuntime, errorKindGoroutines, fmt.Sprintf("goroutine limit exceeded: more than %d goroutines running", r.opts.maxGoroutines))
+	case errors.Is(err, errOutputLimit):
+		r.fail(errorKindRuntime, errorKindOutput, fmt.Sprintf("output limit exceeded: more than %d bytes written", r.opts.maxOutput))
+	case errors.As(err, &p):
+		r.errorLine, r.errorColumn = r.unshift(matchPosition(panicPos, r.stderr.String()))
+		r.failPanic(phase, p.Value, p.Stack)
+	default:
+		r.errorLine, r.errorColumn = errorPosition(err)
+		if !errors.As(err, new(importError)) && !errors.As(err, new(embedError)) {
+			r.errorLine, r.errorColumn = r.unshift(r.errorLine, r.errorColumn)
+		}
+		r.fail(phase, phase, err.Error())
+	}
+}
+
+// fail records a failure of the given phase and finer kind, appending its
+// message to the captured stderr.
+func (r *runner) fail(phase, kind, message string) {
+	r.errorKind, r.failure, r.errorMessage = phase, kind, message
+	r.stderr.WriteString(message)
+}
+
+// failPanic records a panic recovered during the given phase.
+func (r *runner) failPanic(phase string, value interface{}, stack []byte) {
+	r.errorKind, r.failure, r.errorMessage = phase, errorKindPanic, fmt.Sprint(value)
+	r.errorStack = cleanStack(stack)
+	r.stderr.WriteString(formatPanic(value, stack))
+}
+
+// result builds the JS result object from the captured output and the value
+// src evaluated to.
+func (r *runner) result(src source, value reflect.Value) js.Value {
+	result := js.Global().Get("Object").New()
+	result.Set("runId", r.runID)
+	result.Set("sourceHash", src.hash())
+	if r.opts.outputEncoding == "base64" {
+		result.Set("output", base64.StdEncoding.EncodeToString(r.stdout.Bytes()))
+	} else {
+		result.Set("output", r.captured(r.stdout))
+	}
+	result.Set("outputEncoding", r.opts.outputEncoding)
+	if r.opts.normalizeOutput {
+		result.Set("normalizedOutput", normalizeOutput(r.captured(r.stdout), r.opts.stripFinalNewline))
+	}
+	result.Set("logs", r.captured(r.logOutput))
+	if r.opts.fmtOutput {
+		result.Set("fmtOutput", r.captured(r.fmtOutput))
+		result.Set("fmtOutputNote", fmtOutputNote)
+	}
+	if r.opts.structuredErrors {
+		result.Set("error", r.errorObject())
+		result.Set("errorText", r.captured(r.stderr))
+	} else {
+		result.Set("error", r.captured(r.stderr))
+	}
+	result.Set("errorKind", r.errorKind)
+	result.Set("status", r.status())
+	result.Set("producedOutput", r.stdout.Len() > 0)
+	result.Set("mode", src.mode())
+	if isUnsupported(r.errorKind, r.failure, r.errorMessage) {
+		result.Set("warning", unsupportedWarning)
+	}
+	result.Set("errorLine", r.errorLine)
+	result.Set("errorColumn", r.errorColumn)
+	result.Set("executionTimeMs", float64(r.elapsed)/float64(time.Millisecond))
+	if r.opts.transcript {
+		result.Set("transcript", r.transcript.toJS())
+	}
+	var compileErrors []compileError
+	if r.errorKind == errorKindCompile && (r.opts.sourceWithErrors || r.opts.allErrors) {
+		compileErrors = r.compileErrors(src)
+	}
+	if r.opts.sourceWithErrors {
+		if compileErrors != nil {
+			result.Set("sourceWithErrors", sourceWithErrors(src, compileErrors))
+		} else {
+			result.Set("sourceWithErrors", nil)
+		}
+	}
+	if r.opts.allErrors {
+		diagnostics := make([]interface{}, len(compileErrors))
+		for i, e := range compileErrors {
+			diagnostics[i] = e.toJS()
+		}
+		result.Set("diagnostics", diagnostics)
+	}
+	if r.opts.lenientUnused && r.errorKind != errorKindCompile {
+		notes := []interface{}{}
+		for _, e := range unusedDecls(src, true) {
+			notes = append(notes, e.String())
+		}
+		result.Set("notes", notes)
+	}
+	if r.opts.concurrencyNote && usesConcurrency(src) {
+		result.Set("concurrencyNote", concurrencyNote)
+	}
+	if r.failure == errorKindPanic {
+		result.Set("panicTrace", r.panicTrace())
+	}
+	result.Set("metrics", r.metrics())
+	result.Set("leakedGoroutines", r.leaked)
+	result.Set("lateOutput", r.lateOutput)
+	if r.exited {
+		result.Set("exitCode", r.exitCode)
+	}
+	if r.opts.audit != "" {
+		result.Set("audit", r.auditRecord(src))
+	}
+	if r.opts.toJSON {
+		result.Set("json", nil)
+	}
+	// Full programs evaluate to their package, not a value worth showing
+	if value.IsValid() && value.CanInterface() && !src.isProgram() {
+		result.Set("result", fmt.Sprintf("%v", value.Interface()))
+		result.Set("resultType", value.Type().String())
+		if r.opts.toJSON {
+			text, note := jsonValue(value)
+			result.Set("json", text)
+			result.Set("jsonNote", note)
+		}
+		if r.resultTypes != nil {
+			setResults(result, value, r.resultTypes)
+		}
+	}
+	return result
+}
+
+// status summarizes how the last run ended: "ok", "timeout" (a deadlock
+// included), "cancelled", "panic", or "error" for any other failure, and for
+// an os.Exit with a nonzero code.
+func (r *runner) status() string {
+	switch r.failure {
+	case "":
+		if r.exited && r.exitCode != 0 {
+			return "error"
+		}
+		return "ok"
+	case errorKindTimeout, errorKindDeadlock:
+		return "timeout"
+	case errorKindCancelled, errorKindPanic:
+		return r.failure
+	}
+	return "error"
+}
+
+// metrics returns the {stdoutBytes, stderrBytes, stdoutLines,
+// goroutinesBefore, goroutinesAfter, peakHeapBytes} object of the last run.
+// Byte counts are those kept after truncation. The goroutine counts and heap
+// size are process-wide, so overlapping runs blur them.
+func (r *runner) metrics() js.Value {
+	m := js.Global().Get("Object").New()
+	m.Set("stdoutBytes", r.stdout.Len())
+	m.Set("stderrBytes", r.stderr.Len())
+	m.Set("stdoutLines", lineCount(r.stdout.Bytes()))
+	m.Set("goroutinesBefore", r.goroutinesBefore)
+	m.Set("goroutinesAfter", runtime.NumGoroutine())
+	m.Set("peakHeapBytes", r.peakHeap)
+	return m
+}
+
+// lineCount counts the lines of b, including a last one without a newline.
+func lineCount(b []byte) int {
+	n := bytes.Count(b, []byte("\n"))
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// settle resolves a run's Promise to its result, unless the rejectOnError
+// option is set and the run failed. The Promise is then rejected with the
+// errorObject of the failure, along with the output, logs and result fields
+// of the run, the latter holding the whole result object.
+func (r *runner) settle(result, resolve, reject js.Value) {
+	if !r.opts.rejectOnError || r.failure == "" {
+		resolve.Invoke(result)
+		return
+	}
+	e := r.errorObject()
+	e.Set("output", result.Get("output"))
+	e.Set("logs", result.Get("logs"))
+	e.Set("result", result)
+	reject.Invoke(e)
+}
+
+// errorObject returns the last failure as a {message, kind, line, column,
+// stack} object, or null when there was none.
+func (r *runner) errorObject() js.Value {
+	if r.failure == "" {
+		return js.Null()
+	}
+	e := js.Global().Get("Object").New()
+	e.Set("message", r.errorMessage)
+	e.Set("kind", r.failure)
+	e.Set("line", r.errorLine)
+	e.Set("column", r.errorColumn)
+	e.Set("stack", r.errorStack)
+	return e
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutBusyLoop(t *testing.T) {
+	for _, code := range []interface{}{
+		"package main\n\nfunc main() {\n\tfor {\n\t}\n}",
+		"x := 0\nfor {\n\tx++\n}",
+		"package main\n\nfunc main() {\n\tgo func() {\n\t\tfor {\n\t\t}\n\t}()\n\tselect {}\n}",
+		map[string]interface{}{
+			"main.go": "package main\n\nfunc main() {\n\tspin()\n}",
+			"spin.go": "package main\n\nfunc spin() {\n\tfor {\n\t}\n}",
+		},
+	} {
+		start := time.Now()
+		result := executeGo(t, code, map[string]interface{}{"timeout": 100})
+		if got := result.Get("status").String(); got != "timeout" {
+			t.Errorf("%v: status = %q, want timeout", code, got)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%v: took %v to time out", code, elapsed)
+		}
+	}
+}
+
+func TestOutputLimit(t *testing.T) {
+	code := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfor i := 0; i < 1000; i++ {\n\t\tfmt.Println(\"x\")\n\t}\n}"
+	result := executeGo(t, code, map[string]interface{}{"maxOutputBytes": 100})
+	if got := result.Get("status").String(); got != "ok" {
+		t.Errorf("status = %q, want ok (%s)", got, result.Get("error").String())
+	}
+	if output := result.Get("output").String(); !strings.HasPrefix(output, "x\nx\n") || !strings.Contains(output, "output truncated") {
+		t.Errorf("output = %q, want it truncated", output)
+	}
+}
+
+func TestOutputLimitStopsRun(t *testing.T) {
+	code := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfor {\n\t\tfmt.Println(\"x\")\n\t}\n}"
+	start := time.Now()
+	result := executeGo(t, code, map[string]interface{}{"maxOutputBytes": 100, "stopOnOutputLimit": true, "structuredErrors": true})
+	if got := result.Get("error").Get("kind").String(); got != errorKindOutput {
+		t.Errorf("error kind = %q, want %q", got, errorKindOutput)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %v to stop", elapsed)
+	}
+	if output := result.Get("output").String(); !strings.HasPrefix(output, "x\nx\n") || !strings.Contains(output, "output truncated") {
+		t.Errorf("output = %q, want it truncated", output)
+	}
+}
+
+// TestTimeoutPartialOutput reads the output of a run timing out while it
+// writes. The race detector is not available for js/wasm, and the wasm
+// scheduler never runs the writing and reading goroutines in parallel, so
+// TestOutputCapturerLocks checks that both go through the lock instead.
+func TestTimeoutPartialOutput(t *testing.T) {
+	code := "package main\n\nimport (\n\t\"fmt\"\n\t\"time\"\n)\n\nfunc main() {\n\tfmt.Println(\"before\")\n\tfor i := 0; ; i++ {\n\t\tfmt.Println(i)\n\t\ttime.Sleep(time.Millisecond)\n\t}\n}"
+	result := executeGo(t, code, map[string]interface{}{"timeout": 200})
+	if got := result.Get("status").String(); got != "timeout" {
+		t.Errorf("status = %q, want timeout", got)
+	}
+	if output := result.Get("output").String(); !strings.HasPrefix(output, "before\n0\n1\n") {
+		t.Errorf("output = %.40q, want the lines printed before the timeout", output)
+	}
+}
+
+func TestOutputCapturerLocks(t *testing.T) {
+	o := &outputCapturer{limit: defaultMaxOutput}
+	for name, access := range map[string]func(){
+		"Write":  func() { o.Write([]byte("x")) },
+		"String": func() { _ = o.String() },
+		"Bytes":  func() { _ = o.Bytes() },
+		"Len":    func() { _ = o.Len() },
+	} {
+		done := make(chan struct{})
+		o.mu.Lock()
+		go func() {
+			defer close(done)
+			access()
+		}()
+		select {
+		case <-done:
+			t.Errorf("%s did not wait for the lock", name)
+		case <-time.After(20 * time.Millisecond):
+		}
+		o.mu.Unlock()
+		<-done
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// safeModePackages are the packages the safeMode option leaves out of the
+// interpreter, those giving access to memory, the host filesystem or the
+// process. Some, such as os/exec and plugin, are never available, but are
+// still reported as disabled.
+var safeModePackages = map[string]bool{
+	"unsafe":        true,
+	"os":            true,
+	"os/exec":       true,
+	"os/signal":     true,
+	"os/user":       true,
+	"io/ioutil":     true,
+	"path/filepath": true,
+	"plugin":        true,
+	"syscall":       true,
+	"syscall/js":    true,
+	"runtime/debug": true,
+	"runtime/pprof": true,
+	"runtime/trace": true,
+	"log/syslog":    true,
+	"go/build":      true,
+	"go/importer":   true,
+	"net/http/cgi":  true,
+}
+
+// safeModeSymbols are the symbols the safeMode option leaves out of packages
+// it keeps, by package, those reading or writing memory at any address, or
+// opening files of the host by name. The methods of reflect.Value reading
+// memory, such as UnsafePointer, cannot be left out, but are harmless without
+// a way to build a pointer from an address.
+var safeModeSymbols = map[string][]string{
+	"reflect":         {"NewAt", "SliceHeader", "StringHeader"},
+	"text/template":   {"ParseFiles", "ParseGlob"},
+	"html/template":   {"ParseFiles", "ParseGlob"},
+	"archive/zip":     {"OpenReader"},
+	"debug/buildinfo": {"ReadFile"},
+	"debug/elf":       {"Open"},
+	"debug/macho":     {"Open", "OpenFat"},
+	"debug/pe":        {"Open"},
+	"debug/plan9obj":  {"Open"},
+	"go/parser":       {"ParseDir"},
+	"net/http":        {"Dir", "ServeFile"},
+}
+
+// safeModeMethods are the methods opening files of the host by name, by
+// package of their type, which cannot be left out of the interpreter. In
+// files importing the package, checkSafeMode rejects any selector of their
+// names, whatever its operand.
+var safeModeMethods = map[string][]string{
+	"text/template": {"ParseFiles", "ParseGlob"},
+	"html/template": {"ParseFiles", "ParseGlob"},
+}
+
+// safeModeWrappers replace symbols of the packages safe mode keeps, by
+// package, with functions refusing the arguments that would open files of
+// the host.
+var safeModeWrappers = map[string]map[string]reflect.Value{
+	"go/parser": {"ParseFile": reflect.ValueOf(safeParseFile)},
+}
+
+// safeParseFile is parser.ParseFile, failing rather than reading filename
+// when src is nil.
+func safeParseFile(fset *token.FileSet, filename string, src any, mode parser.Mode) (*ast.File, error) {
+	if src == nil {
+		return nil, errors.New("parser.ParseFile without source is disabled in safe mode")
+	}
+	return parser.ParseFile(fset, filename, src, mode)
+}
+
+// checkSafeMode rejects src using a symbol of safeModeSymbols through its
+// package's name, or a method of safeModeMethods. Code that fails to parse is
+// left for the compiler to report, and sessions using a package imported by
+// an earlier eval are only stopped by the symbol being undefined.
+func checkSafeMode(src source) error {
+	fset, files, shift, _ := parseGiven(src, 0)
+	for _, f := range files {
+		blocked := map[string][]string{}
+		var methods []string
+		for _, spec := range f.Imports {
+			p, _ := strconv.Unquote(spec.Path.Value)
+			if symbols := safeModeSymbols[p]; symbols != nil {
+				name := path.Base(p)
+				if spec.Name != nil {
+					name = spec.Name.Name
+				}
+				blocked[name] = symbols
+			}
+			methods = append(methods, safeModeMethods[p]...)
+		}
+
+		var found error
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok || found != nil {
+				return found == nil
+			}
+			msg := ""
+			// Package names are left unresolved
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil && slices.Contains(blocked[id.Name], sel.Sel.Name) {
+				msg = fmt.Sprintf("%s.%s is disabled in safe mode", id.Name, sel.Sel.Name)
+			} else if slices.Contains(methods, sel.Sel.Name) {
+				msg = fmt.Sprintf("method %s is disabled in safe mode", sel.Sel.Name)
+			}
+			if msg != "" {
+				pos := fset.Position(sel.Pos())
+				pos.Line, pos.Column = unshift(shift, pos.Line, pos.Column)
+				found = importError{scanner.ErrorList{{Pos: pos, Msg: msg}}}
+			}
+			return true
+		})
+		if found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYaegiStdlibNotImportable(t *testing.T) {
+	code := `package main
+
+import (
+	"fmt"
+
+	"github.com/traefik/yaegi/stdlib"
+)
+
+func main() {
+	read := stdlib.Symbols["os/os"]["ReadFile"].Interface().(func(string) ([]byte, error))
+	fmt.Println(read("/etc/hostname"))
+}`
+	for _, opts := range []map[string]interface{}{
+		nil,
+		{"safeMode": true},
+		{"disabledPackages": []interface{}{"os"}},
+	} {
+		result := executeGo(t, code, opts)
+		if got := result.Get("errorKind").String(); got != errorKindCompile {
+			t.Errorf("with %v, errorKind = %q, want %q", opts, got, errorKindCompile)
+		}
+		if got := result.Get("error").String(); !strings.Contains(got, "neither in the standard library") {
+			t.Errorf("with %v, error = %q", opts, got)
+		}
+	}
+}
+
+func TestSafeModeRejects(t *testing.T) {
+	for _, tt := range []struct{ code, want string }{
+		{`package main
+import "os/exec"
+func main() { exec.Command("ls").Run() }`, "package os/exec is disabled in safe mode"},
+		{`package main
+import "text/template"
+func main() { template.ParseFiles("/etc/hostname") }`, "template.ParseFiles is disabled in safe mode"},
+		{`package main
+import "html/template"
+func main() { template.New("t").ParseGlob("/etc/*") }`, "method ParseGlob is disabled in safe mode"},
+		{`package main
+import "archive/zip"
+func main() { zip.OpenReader("/etc/hostname") }`, "zip.OpenReader is disabled in safe mode"},
+		{`package main
+import "debug/elf"
+func main() { elf.Open("/bin/sh") }`, "elf.Open is disabled in safe mode"},
+		{`package main
+import "net/http"
+func main() { http.FileServer(http.Dir("/")) }`, "http.Dir is disabled in safe mode"},
+	} {
+		result := executeGo(t, tt.code, map[string]interface{}{"safeMode": true})
+		if got := result.Get("error").String(); !strings.Contains(got, tt.want) {
+			t.Errorf("error = %q, want it to contain %q", got, tt.want)
+		}
+	}
+}
+
+func TestSafeModeParseFile(t *testing.T) {
+	code := `package main
+import ("fmt"; "go/parser"; "go/token")
+func main() {
+	_, err := parser.ParseFile(token.NewFileSet(), "/etc/hostname", nil, 0)
+	fmt.Println(err)
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p", 0)
+	fmt.Println(f.Name, err)
+}`
+	result := executeGo(t, code, map[string]interface{}{"safeMode": true})
+	want := "parser.ParseFile without source is disabled in safe mode\np <nil>\n"
+	if got := result.Get("output").String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
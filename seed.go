@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// lockedSource makes a rand.Source safe for concurrent use, as the source
+// behind the top-level math/rand functions is.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// seedRand makes the top-level math/rand functions of the interpreter draw
+// from a source seeded with seed. Since Go 1.24 rand.Seed no longer affects
+// them, so they are shadowed instead.
+func (r *runner) seedRand(seed int64) {
+	rnd := rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+	r.use(interp.Exports{"math/rand/rand": {
+		"ExpFloat64":  reflect.ValueOf(rnd.ExpFloat64),
+		"Float32":     reflect.ValueOf(rnd.Float32),
+		"Float64":     reflect.ValueOf(rnd.Float64),
+		"Int":         reflect.ValueOf(rnd.Int),
+		"Int31":       reflect.ValueOf(rnd.Int31),
+		"Int31n":      reflect.ValueOf(rnd.Int31n),
+		"Int63":       reflect.ValueOf(rnd.Int63),
+		"Int63n":      reflect.ValueOf(rnd.Int63n),
+		"Intn":        reflect.ValueOf(rnd.Intn),
+		"NormFloat64": reflect.ValueOf(rnd.NormFloat64),
+		"Perm":        reflect.ValueOf(rnd.Perm),
+		"Read":        reflect.ValueOf(rnd.Read),
+		"Seed":        reflect.ValueOf(rnd.Seed),
+		"Shuffle":     reflect.ValueOf(rnd.Shuffle),
+		"Uint32":      reflect.ValueOf(rnd.Uint32),
+		"Uint64":      reflect.ValueOf(rnd.Uint64),
+	}})
+}
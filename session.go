@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall/js"
+)
+
+// session is a long-lived runner exposed to JS, so that declarations made by
+// one eval stay visible to the next.
+type session struct {
+	// mu serializes the calls overlapping on the session, which would
+	// otherwise reset and share the runner's output buffers
+	mu sync.Mutex
+	// runner is nil once the session is closed
+	runner atomic.Pointer[runner]
+	// decls are the top-level identifiers declared so far, each listed once,
+	// where it was first declared
+	decls []declaration
+}
+
+// declare records the declarations of src, which evaluated successfully.
+func (s *session) declare(src source) {
+	for _, d := range declarations(src) {
+		i := slices.IndexFunc(s.decls, func(e declaration) bool { return e.name == d.name })
+		if i < 0 {
+			s.decls = append(s.decls, d)
+		} else {
+			s.decls[i] = d
+		}
+	}
+}
+
+// lock waits for the calls before it to finish and returns the session's
+// runner, or nil if it was closed meanwhile. The caller must call unlock.
+func (s *session) lock() *runner {
+	s.mu.Lock()
+	return s.runner.Load()
+}
+
+func (s *session) unlock() { s.mu.Unlock() }
+
+// eval evaluates the code given as first argument in the session, with the
+// timeout of the options given as second argument if any, or of opts, those
+// of the session.
+func (s *session) eval(args []js.Value, opts runOptions) js.Value {
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		if len(args) == 0 || args[0].Type() != js.TypeString {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+		r := s.lock()
+		defer s.unlock()
+		if r == nil {
+			reject.Invoke(errorObject("Session is closed"))
+			return
+		}
+
+		timeout := opts.timeout
+		if len(args) > 1 {
+			timeout = parseRunOptions(args[1]).timeout
+		}
+		src := source{code: args[0].String()}
+		result := r.eval(ctx, src, timeout)
+		if r.failure == "" {
+			s.declare(src)
+		}
+		resolve.Invoke(result)
+	})
+}
+
+// createSessionWrapper returns a session handle with eval, call, symbols,
+// getVar and close methods.
+// It takes the same optional options as executeGoCode; stdin is bound for the
+// lifetime of the session, while the timeout may be overridden per eval.
+func createSessionWrapper(this js.Value, args []js.Value) interface{} {
+	opts := parseRunOptions(argAt(args, 0))
+	s := &session{}
+	s.runner.Store(acquireRunner(opts))
+	warmSpareRunner()
+
+	handle := js.Global().Get("Object").New()
+	handle.Set("eval", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return s.eval(args, opts)
+	}))
+	handle.Set("call", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+			if len(args) == 0 || args[0].Type() != js.TypeString {
+				reject.Invoke(errorObject("Invalid or missing function name"))
+				return
+			}
+			r := s.lock()
+			defer s.unlock()
+			if r == nil {
+				reject.Invoke(errorObject("Session is closed"))
+				return
+			}
+
+			timeout := opts.timeout
+			if len(args) > 2 {
+				timeout = parseRunOptions(args[2]).timeout
+			}
+			resolve.Invoke(r.call(ctx, args[0].String(), argAt(args, 1), timeout))
+		})
+	}))
+	handle.Set("symbols", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return newPromise(func(resolve, reject js.Value) {
+			r := s.lock()
+			defer s.unlock()
+			if r == nil {
+				reject.Invoke(errorObject("Session is closed"))
+				return
+			}
+
+			symbols := make([]interface{}, len(s.decls))
+			for i, d := range s.decls {
+				symbols[i] = r.describe(d)
+			}
+			resolve.Invoke(symbols)
+		})
+	}))
+	handle.Set("getVar", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return newPromise(func(resolve, reject js.Value) {
+			if len(args) == 0 || args[0].Type() != js.TypeString || !varPath.MatchString(args[0].String()) {
+				reject.Invoke(errorObject("Invalid or missing variable name"))
+				return
+			}
+			r := s.lock()
+			defer s.unlock()
+			if r == nil {
+				reject.Invoke(errorObject("Session is closed"))
+				return
+			}
+			resolve.Invoke(r.getVar(args[0].String()))
+		})
+	}))
+	handle.Set("close", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		// Drop the interpreter so it can be garbage collected
+		s.runner.Store(nil)
+		return nil
+	}))
+
+	return handle
+}
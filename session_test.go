@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"syscall/js"
+	"testing"
+	"time"
+)
+
+// printLoop prints s five times, sleeping in between so that overlapping
+// runs interleave.
+func printLoop(s string) string {
+	return `for i := 0; i < 5; i++ { fmt.Print("` + s + `"); time.Sleep(time.Millisecond) }`
+}
+
+func TestOverlappingRuns(t *testing.T) {
+	program := func(s string) string {
+		return `package main; import ("fmt"; "time"); func main() { ` + printLoop(s) + ` }`
+	}
+	a := executeGoCodeWrapper(js.Undefined(), []js.Value{js.ValueOf(program("a"))}).(js.Value)
+	b := executeGoCodeWrapper(js.Undefined(), []js.Value{js.ValueOf(program("b"))}).(js.Value)
+	for want, p := range map[string]js.Value{"aaaaa": a, "bbbbb": b} {
+		if got := awaitValue(p).Get("output").String(); got != want {
+			t.Errorf("overlapping executeGoCode: output %q, want %q", got, want)
+		}
+	}
+
+	handle := createSessionWrapper(js.Undefined(), nil).(js.Value)
+	defer handle.Call("close")
+	awaitValue(handle.Call("eval", `import ("fmt"; "time")`))
+	a = handle.Call("eval", printLoop("a"))
+	b = handle.Call("eval", printLoop("b"))
+	for want, p := range map[string]js.Value{"aaaaa": a, "bbbbb": b} {
+		result := awaitValue(p)
+		if got := result.Get("output").String(); got != want {
+			t.Errorf("overlapping session evals: output %q, want %q (%s)", got, want, strings.TrimSpace(result.Get("error").String()))
+		}
+	}
+}
+
+func TestSpinningCallStopped(t *testing.T) {
+	decls := `import "fmt"; func Spin() { ` + spin + ` }; func Hello() { fmt.Print("hello") }`
+	r := newRunner(defaultRunOptions())
+	r.eval(context.Background(), source{code: decls}, time.Second)
+	result := r.call(context.Background(), "Spin", js.Undefined(), 200*time.Millisecond)
+	if got := result.Get("status").String(); got != "timeout" {
+		t.Errorf("call: status = %q, want timeout", got)
+	}
+	assertStopped(t, r)
+
+	// The session's runner is reused for the next call
+	handle := createSessionWrapper(js.Undefined(), nil).(js.Value)
+	defer handle.Call("close")
+	awaitValue(handle.Call("eval", decls))
+	timeout := map[string]interface{}{"timeout": 200}
+	awaitValue(handle.Call("call", "Spin", js.ValueOf([]interface{}{}), timeout))
+	result = awaitValue(handle.Call("call", "Hello", js.ValueOf([]interface{}{})))
+	if got := result.Get("output").String(); got != "hello" {
+		t.Errorf("session call after a timed out one: output %q, want hello", got)
+	}
+}
+
+func TestGetVarType(t *testing.T) {
+	handle := createSessionWrapper(js.Undefined(), nil).(js.Value)
+	defer handle.Call("close")
+	awaitValue(handle.Call("eval", `type P int; var x = 5; var ptr *int; func f() {}`))
+	for name, want := range map[string]string{"P": "type", "x": "value", "ptr": "value", "f": "value"} {
+		v := awaitValue(handle.Call("getVar", name))
+		if got := v.Get("kind").String(); got != want || !v.Get("defined").Bool() {
+			t.Errorf("getVar(%q): kind %q, defined %v, want %q", name, got, v.Get("defined").Bool(), want)
+		}
+	}
+	if v := awaitValue(handle.Call("getVar", "P")); v.Get("value").String() != "" {
+		t.Errorf("getVar(P): value %q, want none", v.Get("value").String())
+	}
+	if v := awaitValue(handle.Call("getVar", "x")); v.Get("value").String() != "5" {
+		t.Errorf("getVar(x): value %q, want 5", v.Get("value").String())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// Hosts serving several users keep their sessions in the pool, by opaque id,
+// rather than holding session handles. Each pooled session has a runner of
+// its own, so neither its interpreter nor its output is shared with another.
+const (
+	// maxPooledSessions is the number of sessions the pool holds at most
+	maxPooledSessions = 32
+	// defaultIdleTimeout is how long a pooled session is kept unused
+	defaultIdleTimeout = 10 * time.Minute
+)
+
+// pooledSession is a session of the pool, evicted once idle for idle.
+type pooledSession struct {
+	*session
+	opts  runOptions
+	idle  time.Duration
+	timer *time.Timer
+}
+
+var (
+	poolMu sync.Mutex
+	pool   = map[string]*pooledSession{}
+)
+
+// createPooledSessionWrapper adds a session to the pool, taking the same
+// optional options as createSession, along with idleTimeout, the
+// milliseconds after the last eval when the session is evicted. It returns
+// {id}, or an {error} object when the pool is full.
+func createPooledSessionWrapper(this js.Value, args []js.Value) interface{} {
+	full := func() bool { return len(pool) >= maxPooledSessions }
+	fullError := errorObject(fmt.Sprintf("Too many sessions: at most %d may be open", maxPooledSessions))
+	poolMu.Lock()
+	if full() {
+		poolMu.Unlock()
+		return fullError
+	}
+	poolMu.Unlock()
+
+	opts := parseRunOptions(argAt(args, 0))
+	idle := defaultIdleTimeout
+	if o := argAt(args, 0); o.Type() == js.TypeObject {
+		idle = parseTimeout(o.Get("idleTimeout"), idle)
+	}
+	s := &session{}
+	s.runner.Store(acquireRunner(opts))
+	warmSpareRunner()
+
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	// Another session may have taken the last place meanwhile
+	if full() {
+		return fullError
+	}
+	id := newRunID()
+	p := &pooledSession{session: s, opts: opts, idle: idle}
+	p.timer = time.AfterFunc(idle, func() { evictIdle(id) })
+	pool[id] = p
+
+	result := js.Global().Get("Object").New()
+	result.Set("id", id)
+	return result
+}
+
+// evalPooledSessionWrapper evaluates the code given as second argument in the
+// pooled session whose id is the first, as the eval method of createSession
+// handles does, options moving to the third argument. The Promise rejects for
+// an unknown id.
+func evalPooledSessionWrapper(this js.Value, args []js.Value) interface{} {
+	p := lookupPooled(argAt(args, 0))
+	if p == nil {
+		return newPromise(func(resolve, reject js.Value) {
+			reject.Invoke(errorObject(fmt.Sprintf("Unknown session %q", argAt(args, 0).String())))
+		})
+	}
+	p.timer.Reset(p.idle)
+	return p.eval(args[1:], p.opts)
+}
+
+// destroyPooledSessionWrapper removes the session whose id is given as first
+// argument from the pool, returning whether there was one. An eval in
+// progress completes, while those queued fail.
+func destroyPooledSessionWrapper(this js.Value, args []js.Value) interface{} {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	id := argAt(args, 0).String()
+	p := pool[id]
+	if p == nil {
+		return false
+	}
+	delete(pool, id)
+	p.timer.Stop()
+	p.runner.Store(nil)
+	return true
+}
+
+func lookupPooled(id js.Value) *pooledSession {
+	if id.Type() != js.TypeString {
+		return nil
+	}
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	return pool[id.String()]
+}
+
+// evictIdle removes the session id from the pool, which its timer found
+// idle, unless an eval is still running on it.
+func evictIdle(id string) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	p := pool[id]
+	if p == nil {
+		return
+	}
+	if !p.mu.TryLock() {
+		p.timer.Reset(p.idle)
+		return
+	}
+	defer p.mu.Unlock()
+	delete(pool, id)
+	p.runner.Store(nil)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"testing/fstest"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// source is the code given to a run: either a single source string, possibly
+// a REPL-style fragment, or several files forming one package.
+type source struct {
+	code string
+	// files maps file names to their contents, when set. Files in a
+	// directory make up the user package imported by the directory's path,
+	// and the others the main package.
+	files map[string]string
+	// wrapped tells code was made a program by wrapSnippet, from snippet,
+	// opening main at offset mainAt of snippet
+	wrapped bool
+	snippet string
+	mainAt  int
+}
+
+// parseSource accepts either a string or an object mapping file names to
+// their contents.
+func parseSource(v js.Value) (source, bool) {
+	switch {
+	case v.Type() == js.TypeString:
+		return wrapSnippet(v.String()), true
+	case v.Type() == js.TypeObject && !js.Global().Get("Array").Call("isArray", v).Bool():
+		files := stringMap(v)
+		return source{files: files}, len(files) > 0
+	}
+	return source{}, false
+}
+
+// isProgram reports whether src is made of full source files rather than a
+// REPL-style fragment.
+func (src source) isProgram() bool {
+	return src.files != nil || isProgram(src.code)
+}
+
+// isProgram reports whether code starts with a package clause, i.e. is a full
+// source file rather than a REPL-style fragment.
+func isProgram(code string) bool {
+	_, err := parser.ParseFile(token.NewFileSet(), "", code, parser.PackageClauseOnly)
+	return err == nil
+}
+
+// wrapSnippet returns the source of code, turned into a program when it is
+// a snippet made of imports followed by statements, which yaegi cannot
+// compile as a fragment. The imports are hoisted into the package clause
+// line, and the statements wrapped into main, so that positions only shift
+// on the first line, as for the fragments yaegi wraps itself.
+func wrapSnippet(code string) source {
+	src := source{code: code}
+	if isProgram(code) || parseFragment(code) != nil {
+		return src
+	}
+	const pkg = "package main;"
+	f, err := parser.ParseFile(token.NewFileSet(), "", pkg+code, parser.ImportsOnly)
+	if err != nil || len(f.Imports) == 0 {
+		return src
+	}
+	end := int(f.Decls[len(f.Decls)-1].End()) - 1 - len(pkg)
+	wrapped := pkg + code[:end] + snippetMain + code[end:] + "\n}"
+	if _, err := parser.ParseFile(token.NewFileSet(), "", wrapped, parser.SkipObjectResolution); err != nil {
+		return src
+	}
+	return source{code: wrapped, wrapped: true, snippet: code, mainAt: end}
+}
+
+// snippetMain is what wrapSnippet inserts after the imports of a snippet to
+// open main.
+const snippetMain = "; func main() {"
+
+// hash returns the hex SHA-256 of src as given, for the sourceHash result
+// field. Files are hashed in name order, each name and contents preceded by
+// its length, so that no two sources share the input hashed.
+func (src source) hash() string {
+	h := sha256.New()
+	if src.files == nil {
+		io.WriteString(h, src.given())
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	names := make([]string, 0, len(src.files))
+	for name := range src.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, s := range []string{name, src.files[name]} {
+			fmt.Fprintf(h, "%d:", len(s))
+			io.WriteString(h, s)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// given returns the code of src as given, before wrapSnippet.
+func (src source) given() string {
+	if src.wrapped {
+		return src.snippet
+	}
+	return src.code
+}
+
+// mode returns how src is run: as given, when "program", after wrapSnippet
+// made it one, when "wrapped", or as a fragment yaegi wraps itself.
+func (src source) mode() string {
+	switch {
+	case src.wrapped:
+		return "wrapped"
+	case src.isProgram():
+		return "program"
+	}
+	return "fragment"
+}
+
+// yaegiMainWrap is what yaegi puts before fragment statements, on their first
+// line, to compile them as the body of main.
+const yaegiMainWrap = "package main; func main() {"
+
+// columnShift returns how src moves once wrapped to compile, by wrapSnippet
+// or yaegi, and so the positions reported for it.
+func (src source) columnShift() posShift {
+	if src.files != nil {
+		return posShift{}
+	}
+	if src.wrapped {
+		before := src.snippet[:src.mainAt]
+		return posShift{
+			first:    len("package main;"),
+			line:     1 + strings.Count(before, "\n"),
+			column:   len(before) - strings.LastIndex(before, "\n"),
+			inserted: len(snippetMain),
+		}
+	}
+	switch wrap := wrapFragment(src.code); {
+	case wrap == "":
+		return posShift{}
+	case strings.HasSuffix(wrap, "{"):
+		return posShift{first: len(yaegiMainWrap)}
+	default:
+		return posShift{first: len(wrap)}
+	}
+}
+
+// compileSource checks the imports of src, for unused ones too with the
+// strictImports option, and its uses of symbols disabled in safe mode, makes
+// the auto-imported packages available, and compiles src without running it,
+// after embedding the files of its //go:embed directives.
+func (r *runner) compileSource(src source) (*interp.Program, error) {
+	if r.opts.strictImports {
+		if err := checkUnusedImports(src); err != nil {
+			return nil, err
+		}
+	}
+	if r.opts.safeMode {
+		if err := checkSafeMode(src); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.autoImport(src); err != nil {
+		return nil, err
+	}
+	if src.files == nil {
+		// Unlike fragments, which parse with positions relative to the
+		// code, wrapped snippets are parsed as wrapped
+		var shift posShift
+		if src.wrapped {
+			shift = src.columnShift()
+		}
+		code, err := embedFiles(src.code, r.opts.embedFiles)
+		if err != nil {
+			return nil, unshiftErrors(err, shift)
+		}
+		if err := checkImports(code, r.opts, nil); err != nil {
+			return nil, unshiftErrors(err, shift)
+		}
+		return r.compileCode(code)
+	}
+
+	files := make(map[string]string, len(src.files))
+	for name, code := range src.files {
+		code, err := embedFiles(code, r.opts.embedFiles)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = code
+	}
+	main, packages := splitPackages(files)
+	if len(main) == 0 {
+		return nil, errors.New("no files of the main package, outside directories")
+	}
+	local := map[string]bool{}
+	r.packages.files = fstest.MapFS{}
+	for name, code := range packages {
+		local[path.Dir(name)] = true
+		r.packages.files[path.Join("src", name)] = &fstest.MapFile{Data: []byte(code)}
+	}
+	for _, code := range files {
+		if err := checkImports(code, r.opts, local); err != nil {
+			return nil, err
+		}
+	}
+	return r.compileFiles(main)
+}
+
+// splitPackages separates the files of the main package, at the root, from
+// those of the user packages, in directories named after their import path.
+func splitPackages(files map[string]string) (main, packages map[string]string) {
+	main, packages = map[string]string{}, map[string]string{}
+	for name, code := range files {
+		if path.Dir(name) == "." {
+			main[name] = code
+		} else {
+			packages[name] = code
+		}
+	}
+	return main, packages
+}
+
+// packageFS is the filesystem yaegi imports user packages from, with the
+// sources of the last compiled program. It is set once the interpreter is
+// created, which fixes the filesystem.
+type packageFS struct {
+	files fstest.MapFS
+}
+
+func (p *packageFS) Open(name string) (fs.File, error) { return p.files.Open(name) }
+
+// compileFiles compiles files as a single package. The files are merged into
+// one AST, keeping each unique import once, since yaegi compiles a single
+// node at a time. Positions still refer to the original file names.
+func (r *runner) compileFiles(files map[string]string) (*interp.Program, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := &ast.File{}
+	var imports, decls []ast.Decl
+	seen := map[string]bool{}
+	for _, name := range names {
+		f, err := parser.ParseFile(r.interp.FileSet(), name, files[name], parser.DeclarationErrors)
+		if err != nil {
+			return nil, err
+		}
+		if merged.Name == nil {
+			merged.Name = f.Name
+		} else if f.Name.Name != merged.Name.Name {
+			return nil, fmt.Errorf("%s: found packages %s and %s", name, merged.Name.Name, f.Name.Name)
+		}
+
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			key := path
+			if spec.Name != nil {
+				key = spec.Name.Name + " " + path
+			}
+			if !seen[key] {
+				seen[key] = true
+				imports = append(imports, &ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{spec}})
+			}
+		}
+		for _, decl := range f.Decls {
+			if gen, ok := decl.(*ast.GenDecl); !ok || gen.Tok != token.IMPORT {
+				decls = append(decls, decl)
+			}
+		}
+	}
+	if !r.stepping() {
+		// Imports are scoped by file, and the merged one is not that of
+		// the import of useYield; neither is the package, unless main, that
+		// of its counter
+		imports = append(imports, yieldImport())
+		if merged.Name.Name != "main" {
+			decls = append(decls, yieldCounterDecl())
+		}
+	}
+	merged.Decls = append(imports, decls...)
+	if !r.stepping() {
+		addYields(merged)
+	}
+
+	return r.interp.CompileAST(merged)
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestWrappedSnippetErrorPosition(t *testing.T) {
+	for _, c := range []struct {
+		code         string
+		line, column int
+	}{
+		{`import "fmt"; fmt.Println(zz)`, 1, 27},
+		{"import \"fmt\"\nfmt.Println(zz)", 2, 13},
+		{"import (\n\t\"fmt\"\n); fmt.Println(zz)", 3, 16},
+	} {
+		result := executeGo(t, c.code, nil)
+		line, column := result.Get("errorLine").Int(), result.Get("errorColumn").Int()
+		if line != c.line || column != c.column {
+			t.Errorf("%q: error at %d:%d, want %d:%d (%s)", c.code, line, column, c.line, c.column, result.Get("error").String())
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"syscall/js"
+)
+
+// promptReader reads stdin from a JS callback, called for the next line
+// whenever a read finds no buffered input left. The callback returns a
+// string, or a Promise of one, which the read blocks on; null, undefined or
+// an empty string end the input. A newline is appended to lines lacking one.
+//
+// A run timing out while blocked on the callback is abandoned like any other,
+// but its read only returns once the callback settles.
+type promptReader struct {
+	fn  js.Value
+	buf strings.Reader
+	eof bool
+}
+
+func newPromptReader(fn js.Value) *promptReader {
+	return &promptReader{fn: fn}
+}
+
+func (p *promptReader) Read(b []byte) (int, error) {
+	if p.buf.Len() == 0 {
+		if p.eof {
+			return 0, io.EOF
+		}
+		line := awaitValue(p.fn.Invoke())
+		if line.Type() == js.TypeNull || line.Type() == js.TypeUndefined {
+			p.eof = true
+			return 0, io.EOF
+		}
+		s := js.Global().Get("String").Invoke(line).String()
+		if s == "" {
+			p.eof = true
+			return 0, io.EOF
+		}
+		if !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+		p.buf.Reset(s)
+	}
+	return p.buf.Read(b)
+}
+
+// awaitValue returns v, or the value v settles with when it is a Promise. A
+// rejected Promise gives undefined.
+func awaitValue(v js.Value) js.Value {
+	if v.Type() != js.TypeObject || v.Get("then").Type() != js.TypeFunction {
+		return v
+	}
+	settled := make(chan js.Value, 1)
+	onResolve := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		settled <- argAt(args, 0)
+		return nil
+	})
+	onReject := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		settled <- js.Undefined()
+		return nil
+	})
+	defer onResolve.Release()
+	defer onReject.Release()
+	v.Call("then", onResolve, onReject)
+	return <-settled
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// errStepBudget is returned by executeSteps when the budget runs out.
+var errStepBudget = errors.New("instruction budget exceeded")
+
+// slowdownInterval is the number of steps between the pauses of a slowed down
+// run.
+const slowdownInterval = 1000
+
+// stepping reports whether runs are executed by executeSteps, for the
+// maxSteps or slowdownFactor options.
+func (r *runner) stepping() bool {
+	return r.opts.maxSteps > 0 || r.opts.slowdown > 1
+}
+
+// executeSteps executes prog like ExecuteWithContext, but aborts it once it
+// has run more than max steps, when max is positive, counting those of every
+// goroutine. When slowdown is above 1, the run pauses every slowdownInterval
+// steps, for slowdown-1 times as long as it took to run them, so that it
+// takes about slowdown times as long, in proportion to the steps it executes.
+// That is an approximation, of a run already slowed down by stepping.
+//
+// yaegi has no hook counting executed nodes, so the program runs under its
+// debugger, stepping into every node. Each step costs goroutine switches, so
+// this is much slower than a plain run, by two orders of magnitude on wasm;
+// in exchange, the run yields at every step, so addYields is not needed.
+// Steps taken by a goroutine between being continued and interrupted again
+// go uncounted, which makes the budget approximate outside of wasm.
+func (r *runner) executeSteps(ctx context.Context, prog *interp.Program, max int, slowdown float64) (reflect.Value, error) {
+	// Like a plain run, stop once main returns, without waiting for the
+	// other goroutines
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var steps atomic.Int64
+	var exceeded atomic.Bool
+	var pausedMu sync.Mutex
+	paused := time.Now()
+	var dbg *interp.Debugger
+	ready := make(chan struct{})
+	events := func(e *interp.DebugEvent) {
+		<-ready
+		switch e.Reason() {
+		case interp.DebugEnterGoRoutine:
+			dbg.Interrupt(e.GoRoutine(), interp.DebugStepInto)
+		case interp.DebugExitGoRoutine:
+			if e.GoRoutine() == 0 {
+				cancel()
+			}
+		case interp.DebugEntry, interp.DebugStepInto:
+			n := steps.Add(1)
+			if max > 0 && n > int64(max) {
+				exceeded.Store(true)
+				dbg.Terminate()
+				return
+			}
+			if slowdown > 1 && n%slowdownInterval == 0 {
+				// Sleeping blocks the goroutine stepping, and lets the
+				// others run, as they would meanwhile
+				pausedMu.Lock()
+				pause := time.Duration(float64(time.Since(paused)) * (slowdown - 1))
+				pausedMu.Unlock()
+				time.Sleep(pause)
+				pausedMu.Lock()
+				paused = time.Now()
+				pausedMu.Unlock()
+			}
+			// Switching goroutines at every step never lets the JS event
+			// loop run, as yield does
+			yield()
+			// The goroutine only waits to be resumed once this returns.
+			// Step would refuse to resume it if another goroutine sharing
+			// its debugger state is running, as those started by the
+			// program do, so continue it and step again instead.
+			id := e.GoRoutine()
+			go func() {
+				dbg.Continue(id)
+				dbg.Interrupt(id, interp.DebugStepInto)
+			}()
+		}
+	}
+	dbg = r.interp.Debug(ctx, prog, events, nil)
+	close(ready)
+	if err := dbg.Step(0, interp.DebugStepInto); err != nil {
+		return reflect.Value{}, err
+	}
+
+	v, err := dbg.Wait()
+	if exceeded.Load() {
+		return reflect.Value{}, errStepBudget
+	}
+	return v, err
+}
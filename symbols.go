@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"math"
+	"path"
+	"reflect"
+	"sync"
+	"syscall/js"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// Symbols registered from JS, in the form taken by Interpreter.Use. They are
+// merged into every runner configured after registration.
+var (
+	hostSymbolsMu sync.Mutex
+	hostSymbols   = interp.Exports{}
+)
+
+// registerSymbolWrapper adds the symbols of the object given as second
+// argument to the package whose import path is the first, for later runs to
+// import. JS functions become func(...interface{}) interface{}, whose
+// arguments and result are converted to and from JS; other values become
+// variables holding their Go counterpart, with integral numbers as int.
+// It returns an {error} object when the arguments are invalid.
+func registerSymbolWrapper(this js.Value, args []js.Value) interface{} {
+	pkgPath, symbols := argAt(args, 0), argAt(args, 1)
+	if pkgPath.Type() != js.TypeString || !token.IsIdentifier(path.Base(pkgPath.String())) {
+		return errorObject("Invalid or missing package path")
+	}
+	if symbols.Type() != js.TypeObject || isArray(symbols) {
+		return errorObject("Invalid or missing symbols argument")
+	}
+
+	exports := map[string]reflect.Value{}
+	entries := js.Global().Get("Object").Call("entries", symbols)
+	for i := 0; i < entries.Length(); i++ {
+		name, value := entries.Index(i).Index(0).String(), entries.Index(i).Index(1)
+		if !token.IsExported(name) || !token.IsIdentifier(name) {
+			return errorObject(fmt.Sprintf("Symbol %q is not an exported Go identifier", name))
+		}
+		exports[name] = hostValue(value)
+	}
+
+	key := pkgPath.String() + "/" + path.Base(pkgPath.String())
+	hostSymbolsMu.Lock()
+	defer hostSymbolsMu.Unlock()
+	if hostSymbols[key] == nil {
+		hostSymbols[key] = map[string]reflect.Value{}
+	}
+	for name, v := range exports {
+		hostSymbols[key][name] = v
+	}
+	return nil
+}
+
+// hostValue returns the symbol a JS value is registered as.
+func hostValue(v js.Value) reflect.Value {
+	if v.Type() == js.TypeFunction {
+		return reflect.ValueOf(func(args ...interface{}) interface{} {
+			in := make([]interface{}, len(args))
+			for i, a := range args {
+				in[i] = toJS(reflect.ValueOf(a))
+			}
+			return natural(v.Invoke(in...))
+		})
+	}
+	var n interface{}
+	if f, ok := integer(v); ok && f >= math.MinInt && f <= math.MaxInt {
+		n = int(f)
+	} else {
+		n = natural(v)
+	}
+	// Variables are exported as addressable values, like those of stdlib
+	if n == nil {
+		return reflect.ValueOf(&n).Elem()
+	}
+	p := reflect.New(reflect.TypeOf(n))
+	p.Elem().Set(reflect.ValueOf(n))
+	return p.Elem()
+}
+
+// useHostSymbols merges the registered symbols into the runner's interpreter.
+func (r *runner) useHostSymbols() {
+	hostSymbolsMu.Lock()
+	defer hostSymbolsMu.Unlock()
+	if len(hostSymbols) > 0 {
+		r.use(hostSymbols)
+	}
+}
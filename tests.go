@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// testT stands in for testing.T, which cannot be used outside of go test.
+// It records the failures and messages of one test.
+type testT struct {
+	name     string
+	mu       sync.Mutex
+	failed   bool
+	skipped  bool
+	messages []string
+	cleanups []func()
+	// subtests collects the results of t.Run, in the order they finished
+	subtests []*testT
+	elapsed  time.Duration
+	// deadline is that of the whole run, env the environment of the code
+	// tested, and ctx the context of Context, cancelled by cancel
+	deadline time.Time
+	env      testEnv
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// testEnv is the environment of the code tested, which yaegi keeps apart
+// from the host's, through the os functions of the interpreter. They are nil
+// when os is not available.
+type testEnv struct {
+	lookup func(string) (string, bool)
+	set    func(string, string) error
+	unset  func(string) error
+}
+
+// testEnv returns the testEnv of the code run by r.
+func (r *runner) testEnv() testEnv {
+	var env testEnv
+	os := r.interp.Symbols("os")["os"]
+	for name, fn := range map[string]interface{}{"LookupEnv": &env.lookup, "Setenv": &env.set, "Unsetenv": &env.unset} {
+		if v := os[name]; v.IsValid() {
+			reflect.ValueOf(fn).Elem().Set(v)
+		}
+	}
+	if env.lookup == nil || env.set == nil || env.unset == nil {
+		return testEnv{}
+	}
+	return env
+}
+
+func (t *testT) Name() string { return t.name }
+
+func (t *testT) Fail() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failed = true
+}
+
+func (t *testT) Failed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.failed
+}
+
+func (t *testT) FailNow() {
+	t.Fail()
+	runtime.Goexit()
+}
+
+func (t *testT) Log(args ...interface{}) { t.log(fmt.Sprintln(args...)) }
+
+func (t *testT) Logf(format string, args ...interface{}) { t.log(fmt.Sprintf(format, args...)) }
+
+func (t *testT) Error(args ...interface{}) {
+	t.Log(args...)
+	t.Fail()
+}
+
+func (t *testT) Errorf(format string, args ...interface{}) {
+	t.Logf(format, args...)
+	t.Fail()
+}
+
+func (t *testT) Fatal(args ...interface{}) {
+	t.Log(args...)
+	t.FailNow()
+}
+
+func (t *testT) Fatalf(format string, args ...interface{}) {
+	t.Logf(format, args...)
+	t.FailNow()
+}
+
+func (t *testT) Skip(args ...interface{}) {
+	t.Log(args...)
+	t.SkipNow()
+}
+
+func (t *testT) Skipf(format string, args ...interface{}) {
+	t.Logf(format, args...)
+	t.SkipNow()
+}
+
+func (t *testT) SkipNow() {
+	t.mu.Lock()
+	t.skipped = true
+	t.mu.Unlock()
+	runtime.Goexit()
+}
+
+func (t *testT) Skipped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.skipped
+}
+
+func (t *testT) Helper() {}
+
+// Parallel is a no-op: tests run one after the other.
+func (t *testT) Parallel() {}
+
+// Deadline reports when the run of the tests times out.
+func (t *testT) Deadline() (deadline time.Time, ok bool) { return t.deadline, true }
+
+// Context returns a context cancelled once the test ends, before its
+// cleanups run.
+func (t *testT) Context() context.Context { return t.ctx }
+
+// Setenv sets an environment variable of the code tested, restored once the
+// test ends.
+func (t *testT) Setenv(key, value string) {
+	if t.env.set == nil {
+		t.Skip("t.Setenv needs package os, which is not available")
+	}
+	prev, ok := t.env.lookup(key)
+	if err := t.env.set(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			t.env.set(key, prev)
+		} else {
+			t.env.unset(key)
+		}
+	})
+}
+
+// TempDir skips the test, as the code has no filesystem to write to.
+func (t *testT) TempDir() string {
+	t.Skip("t.TempDir is not supported: there is no filesystem to write to")
+	return ""
+}
+
+// Chdir skips the test, as the working directory is the host's.
+func (t *testT) Chdir(dir string) {
+	t.Skip("t.Chdir is not supported: the working directory is the host's")
+}
+
+func (t *testT) Cleanup(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cleanups = append(t.cleanups, f)
+}
+
+// Run runs f as a subtest of t, on a goroutine of its own, and reports
+// whether it passed.
+func (t *testT) Run(name string, f func(t *testT)) bool {
+	sub := &testT{name: t.name + "/" + name, deadline: t.deadline, env: t.env}
+	sub.run(func() { f(sub) })
+	t.mu.Lock()
+	t.subtests = append(t.subtests, sub)
+	t.mu.Unlock()
+	if sub.Failed() {
+		t.Fail()
+	}
+	return !sub.Failed()
+}
+
+func (t *testT) log(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, strings.TrimSuffix(s, "\n"))
+}
+
+// run calls f, which may exit through FailNow or SkipNow, then the cleanups
+// of t. A panic fails t.
+func (t *testT) run(f func()) {
+	start := time.Now()
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			t.cancel()
+			for i := len(t.cleanups) - 1; i >= 0; i-- {
+				t.cleanups[i]()
+			}
+		}()
+		defer func() {
+			if p := recover(); p != nil {
+				t.log(fmt.Sprintf("panic: %v", p))
+				t.Fail()
+			}
+		}()
+		f()
+	}()
+	<-done
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.elapsed = time.Since(start)
+}
+
+// toJS returns the {name, passed, skipped, messages, durationMs} objects for
+// t and its subtests, in that order.
+func (t *testT) toJS() []interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	messages := make([]interface{}, len(t.messages))
+	for i, m := range t.messages {
+		messages[i] = m
+	}
+	results := []interface{}{map[string]interface{}{
+		"name":       t.name,
+		"passed":     !t.failed,
+		"skipped":    t.skipped,
+		"messages":   messages,
+		"durationMs": float64(t.elapsed) / float64(time.Millisecond),
+	}}
+	for _, sub := range t.subtests {
+		results = append(results, sub.toJS()...)
+	}
+	return results
+}
+
+// testFuncType is the type of test functions, once testing.T is shadowed.
+var testFuncType = reflect.TypeOf(func(*testT) {})
+
+// testFuncs returns the names of the Test functions declared by src, in
+// source order, qualified by their package unless it is main.
+func testFuncs(src source) ([]string, error) {
+	files := src.files
+	if files == nil {
+		code := src.code
+		if !isProgram(code) {
+			code = fragmentClause + code
+		}
+		files = map[string]string{"": code}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tests []string
+	for _, name := range names {
+		f, err := parser.ParseFile(token.NewFileSet(), name, files[name], parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !isTestName(fn.Name.Name) {
+				continue
+			}
+			if f.Name.Name != "main" {
+				tests = append(tests, f.Name.Name+"."+fn.Name.Name)
+			} else {
+				tests = append(tests, fn.Name.Name)
+			}
+		}
+	}
+	return tests, nil
+}
+
+// isTestName reports whether name is that of a test function, as go test
+// sees it: Test followed by nothing or by something other than a lower case
+// letter.
+func isTestName(name string) bool {
+	if !strings.HasPrefix(name, "Test") {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(name[len("Test"):])
+	return len(name) == len("Test") || !unicode.IsLower(r)
+}
+
+// runTests evaluates src, with testing.T shadowed by testT, then runs each of
+// its Test functions. The JS result object is that of eval, with the results
+// of the tests in its tests field and whether they all passed in passed. The
+// timeout covers the evaluation and all the tests.
+func (r *runner) runTests(ctx context.Context, src source, timeout time.Duration) (result js.Value) {
+	r.use(interp.Exports{"testing/testing": {"T": reflect.ValueOf((*testT)(nil))}})
+	start := time.Now()
+	deadline := start.Add(timeout)
+	result = r.eval(ctx, src, timeout)
+	var tests []interface{}
+	passed := r.failure == ""
+	defer func() {
+		result.Set("tests", tests)
+		result.Set("passed", passed)
+	}()
+	if r.failure != "" {
+		return result
+	}
+
+	names, err := testFuncs(src)
+	if err != nil {
+		r.fail(errorKindCompile, errorKindCompile, err.Error())
+		return r.result(src, reflect.Value{})
+	}
+	env := r.testEnv()
+	for _, name := range names {
+		t := &testT{name: name[strings.LastIndex(name, ".")+1:], deadline: deadline, env: env}
+		fn, err := r.interp.Eval(name)
+		if err != nil || fn.Type() != testFuncType {
+			continue
+		}
+
+		test := func() { t.run(func() { fn.Call([]reflect.Value{reflect.ValueOf(t)}) }) }
+		if err := r.execute(ctx, test, time.Until(deadline)); err != nil {
+			r.record(errorKindRuntime, err, timeout)
+			t.log(r.errorMessage)
+			t.Fail()
+			tests, passed = append(tests, t.toJS()...), false
+			break
+		}
+		tests = append(tests, t.toJS()...)
+		passed = passed && !t.Failed()
+	}
+	r.elapsed = time.Since(start)
+	return r.result(src, reflect.Value{})
+}
+
+// runTestsWrapper evaluates the package given as first argument, a source
+// string or an object mapping file names to sources, and runs its Test
+// functions. Options, as for executeGoCode, come second.
+func runTestsWrapper(this js.Value, args []js.Value) interface{} {
+	return newCancellablePromise(func(ctx context.Context, resolve, reject js.Value) {
+		src, ok := parseSource(argAt(args, 0))
+		if !ok {
+			reject.Invoke(errorObject("Invalid or missing code argument"))
+			return
+		}
+
+		opts := parseRunOptions(argAt(args, 1))
+		resolve.Invoke(acquireRunner(opts).runTests(ctx, src, opts.timeout))
+		warmSpareRunner()
+	})
+}
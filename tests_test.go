@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"syscall/js"
+	"testing"
+	"time"
+)
+
+// spin is a loop printing a dot every thousand iterations, so that its
+// output tells whether it still runs.
+const spin = `for i := 0; ; i++ { if i%1000 == 0 { fmt.Print(".") } }`
+
+// assertStopped fails t if the code run by r still writes to its stdout.
+func assertStopped(t *testing.T, r *runner) {
+	t.Helper()
+	n := r.stdout.Len()
+	time.Sleep(200 * time.Millisecond)
+	if r.stdout.Len() != n {
+		t.Error("the code still runs once timed out")
+	}
+}
+
+func TestTestTMethods(t *testing.T) {
+	code := `package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSetenv(t *testing.T) {
+	t.Setenv("BOOKER_TEST", "set")
+	if v := os.Getenv("BOOKER_TEST"); v != "set" {
+		t.Errorf("Getenv = %q", v)
+	}
+}
+
+func TestSetenvRestored(t *testing.T) {
+	if _, ok := os.LookupEnv("BOOKER_TEST"); ok {
+		t.Error("BOOKER_TEST still set")
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	if d, ok := t.Deadline(); !ok || d.Before(time.Now()) {
+		t.Errorf("Deadline = %v, %v", d, ok)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if err := t.Context().Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTempDir(t *testing.T) {
+	t.TempDir()
+	t.Error("not skipped")
+}`
+	result := awaitValue(runTestsWrapper(js.Undefined(), []js.Value{js.ValueOf(code)}).(js.Value))
+	if e := result.Get("error").String(); e != "" {
+		t.Fatalf("error = %q", e)
+	}
+	tests := result.Get("tests")
+	for i := 0; i < tests.Length(); i++ {
+		test := tests.Index(i)
+		name, skipped := test.Get("name").String(), test.Get("skipped").Bool()
+		if !test.Get("passed").Bool() {
+			t.Errorf("%s failed: %v", name, test.Get("messages"))
+		}
+		if skipped != (name == "TestTempDir") {
+			t.Errorf("%s skipped = %v", name, skipped)
+		}
+	}
+	if tests.Length() != 5 {
+		t.Errorf("got %d tests, want 5", tests.Length())
+	}
+}
+
+func TestSpinningTestStopped(t *testing.T) {
+	code := "package main\n\nimport (\n\t\"fmt\"\n\t\"testing\"\n)\n\nfunc TestSpin(t *testing.T) { " + spin + " }"
+	r := newRunner(defaultRunOptions())
+	result := r.runTests(context.Background(), source{code: code}, 200*time.Millisecond)
+	if got := result.Get("status").String(); got != "timeout" {
+		t.Errorf("status = %q, want timeout", got)
+	}
+	if result.Get("passed").Bool() {
+		t.Error("passed = true")
+	}
+	assertStopped(t, r)
+}
+
+func TestNonMainPackageLoops(t *testing.T) {
+	code := "package foo\n\nimport \"testing\"\n\nfunc TestLoop(t *testing.T) {\n\tn := 0\n\tfor i := 0; i < 3; i++ {\n\t\tn += i\n\t}\n\tif n != 3 {\n\t\tt.Error(n)\n\t}\n}"
+	result := awaitValue(runTestsWrapper(js.Undefined(), []js.Value{js.ValueOf(code)}).(js.Value))
+	if e := result.Get("error").String(); e != "" {
+		t.Fatalf("error = %q", e)
+	}
+	if tests := result.Get("tests"); tests.Length() != 1 || !result.Get("passed").Bool() {
+		t.Errorf("tests = %d, passed = %v", tests.Length(), result.Get("passed").Bool())
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// unusedDecls returns the imports and, if vars, local variables of src that
+// are never used, the errors Go would reject src with, as the interpreter
+// does not check for them. Variables declared by a fragment outside of any
+// function are left out, as yaegi keeps them for the code evaluated next.
+//
+// Identifiers resolve through the parser's scopes, so a variable counts as
+// used once assigned, unlike for Go.
+func unusedDecls(src source, vars bool) []compileError {
+	fset, files, shift, _ := parseGiven(src, 0)
+	var unused []compileError
+	report := func(pos token.Pos, msg string) {
+		p := fset.Position(pos)
+		line, column := unshift(shift, p.Line, p.Column)
+		unused = append(unused, compileError{p.Filename, line, column, msg})
+	}
+	for _, f := range files {
+		for _, spec := range unusedImports(f) {
+			msg := fmt.Sprintf("%s imported and not used", spec.Path.Value)
+			if spec.Name != nil {
+				msg = fmt.Sprintf("%s imported as %s and not used", spec.Path.Value, spec.Name.Name)
+			}
+			report(spec.Pos(), msg)
+		}
+		if !vars {
+			continue
+		}
+		for _, id := range unusedVars(f) {
+			report(id.Pos(), "declared and not used: "+id.Name)
+		}
+	}
+	return unused
+}
+
+// unusedImports returns the imports of f that no selector refers to, but
+// for blank and dot imports. The name of a package is taken to be the last
+// element of its path, before any major version suffix, as for math/rand/v2.
+func unusedImports(f *ast.File) []*ast.ImportSpec {
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		// Package names are left unresolved, as is any package-level name
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+
+	var unused []*ast.ImportSpec
+	for _, spec := range f.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(p)
+		if isMajorVersion(name) && path.Dir(p) != "." {
+			name = path.Base(path.Dir(p))
+		}
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name != "_" && name != "." && !used[name] {
+			unused = append(unused, spec)
+		}
+	}
+	return unused
+}
+
+// isMajorVersion reports whether elem is a major version suffix of an import
+// path, such as v2.
+func isMajorVersion(elem string) bool {
+	n, err := strconv.Atoi(strings.TrimPrefix(elem, "v"))
+	return strings.HasPrefix(elem, "v") && err == nil && n >= 2
+}
+
+// unusedVars returns the identifiers declaring the variables of the
+// functions of f that are never referred to.
+func unusedVars(f *ast.File) []*ast.Ident {
+	var decls []*ast.Ident
+	declared := map[*ast.Ident]bool{}
+	var collect func(n ast.Node) bool
+	collect = func(n ast.Node) bool {
+		var ids []*ast.Ident
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, e := range n.Lhs {
+					if id, ok := e.(*ast.Ident); ok {
+						ids = append(ids, id)
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				for _, e := range []ast.Expr{n.Key, n.Value} {
+					if id, ok := e.(*ast.Ident); ok {
+						ids = append(ids, id)
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			ids = n.Names
+		}
+		for _, id := range ids {
+			// Only new variables, not those := assigns again
+			if id.Name != "_" && id.Obj != nil && id.Obj.Kind == ast.Var && declaresIdent(id) && !declared[id] {
+				declared[id] = true
+				decls = append(decls, id)
+			}
+		}
+		return true
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil && !isFragmentMain(n) {
+				ast.Inspect(n.Body, collect)
+			}
+		case *ast.FuncLit:
+			ast.Inspect(n.Body, collect)
+		}
+		return true
+	})
+
+	used := map[*ast.Object]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Obj != nil && !declared[id] {
+			used[id.Obj] = true
+		}
+		return true
+	})
+	var unused []*ast.Ident
+	for _, id := range decls {
+		if !used[id.Obj] {
+			unused = append(unused, id)
+		}
+	}
+	return unused
+}
+
+// declaresIdent reports whether id is where its object is declared, rather
+// than a reference to it.
+func declaresIdent(id *ast.Ident) bool {
+	switch d := id.Obj.Decl.(type) {
+	case *ast.AssignStmt:
+		for _, e := range d.Lhs {
+			if e == id {
+				return true
+			}
+		}
+	case *ast.ValueSpec:
+		for _, name := range d.Names {
+			if name == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkUnusedImports rejects the programs of src importing a package they do
+// not use, for the strictImports option, as Go does. Blank and dot imports
+// are allowed. Fragments may import packages for the code evaluated next, and
+// are not checked.
+func checkUnusedImports(src source) error {
+	if !src.isProgram() {
+		return nil
+	}
+	if unused := unusedDecls(src, false); len(unused) > 0 {
+		e := unused[0]
+		pos := token.Position{Filename: e.file, Line: e.line, Column: e.column}
+		return importError{scanner.ErrorList{{Pos: pos, Msg: e.message}}}
+	}
+	return nil
+}
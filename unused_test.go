@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrictImports(t *testing.T) {
+	for _, c := range []struct {
+		imports string
+		unused  bool
+	}{
+		{`"strings"`, true},
+		{`s "strings"`, true},
+		{`_ "strings"`, false},
+		{`_ "image/png"`, false},
+		{`. "strings"`, false},
+	} {
+		code := "package main\n\nimport " + c.imports + "\n\nfunc main() {}"
+		result := executeGo(t, code, map[string]interface{}{"strictImports": true})
+		errText := result.Get("error").String()
+		if c.unused && (result.Get("errorKind").String() != errorKindCompile || !strings.Contains(errText, `"strings"`)) {
+			t.Errorf("import %s: error %q, want it rejected as unused", c.imports, errText)
+		}
+		if !c.unused && errText != "" {
+			t.Errorf("import %s: error %q, want none", c.imports, errText)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"testing/fstest"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// mountFiles serves os.Open, os.ReadFile, os.Stat and ioutil.ReadFile in the
+// interpreter from files, a map of paths to contents, instead of the host
+// filesystem. Relative and absolute paths name the same file. Note that
+// os.Open then returns an fs.File rather than an *os.File.
+func (r *runner) mountFiles(files map[string]string) {
+	mfs := fstest.MapFS{}
+	for name, data := range files {
+		mfs[fsPath(name)] = &fstest.MapFile{Data: []byte(data), Mode: 0o644}
+	}
+
+	open := func(name string) (fs.File, error) {
+		f, err := mfs.Open(fsPath(name))
+		return f, withPath(err, name)
+	}
+	readFile := func(name string) ([]byte, error) {
+		b, err := fs.ReadFile(mfs, fsPath(name))
+		return b, withPath(err, name)
+	}
+	stat := func(name string) (fs.FileInfo, error) {
+		fi, err := fs.Stat(mfs, fsPath(name))
+		return fi, withPath(err, name)
+	}
+
+	r.use(interp.Exports{
+		"os/os": {
+			"Open":     reflect.ValueOf(open),
+			"ReadFile": reflect.ValueOf(readFile),
+			"Stat":     reflect.ValueOf(stat),
+		},
+		"io/ioutil/ioutil": {
+			"ReadFile": reflect.ValueOf(readFile),
+		},
+	})
+}
+
+// fsPath converts a file path to the unrooted form io/fs expects.
+func fsPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// withPath reports a *fs.PathError against the path given by the caller,
+// rather than its io/fs form.
+func withPath(err error, name string) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"syscall/js"
+)
+
+// unsupportedWarning is the warning result field set when a failure looks
+// like a limitation of the interpreter.
+const unsupportedWarning = "this may be a Go feature the interpreter does not support, rather than a bug in the code"
+
+// unsupportedMarkers are substrings of the messages yaegi fails with when it
+// meets something it does not implement, or mishandles it through reflect.
+var unsupportedMarkers = []string{
+	"not implemented",
+	"unsupported",
+	"reflect: ",
+	"reflect.Value.",
+}
+
+// isUnsupported reports whether the failure of the given phase and finer kind,
+// with the given message, is characteristic of the interpreter's limitations
+// rather than of the code it runs. Any panic while compiling is: valid or
+// not, code should only ever fail to compile with an error.
+func isUnsupported(phase, kind, message string) bool {
+	if phase == errorKindCompile && kind == errorKindPanic {
+		return true
+	}
+	if kind != errorKindCompile && kind != errorKindRuntime && kind != errorKindPanic {
+		return false
+	}
+	for _, m := range unsupportedMarkers {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// addWarning appends warning, when not empty, to the warning field of result.
+func addWarning(result js.Value, warning string) {
+	if warning == "" {
+		return
+	}
+	if w := result.Get("warning"); w.Type() == js.TypeString && w.String() != "" {
+		warning = w.String() + "; " + warning
+	}
+	result.Set("warning", warning)
+}
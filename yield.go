@@ -0,0 +1,261 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// The wasm scheduler never preempts a goroutine, so interpreted code looping
+// without blocking would keep the runner from ever seeing its timeout. The
+// code compiled is made to yield instead: addYields inserts a statement
+// counting down in yieldCounter at the start of the bodies of its loops and
+// functions, which calls yield, imported into the main package of every
+// interpreter as yieldName, every yieldInterval of them. Counting with
+// interpreted code rather than in the host function keeps the cost down, as
+// calls to host functions are slow, and so does counting down, which takes
+// the fewest interpreted operations. Code of the user packages of a map of
+// files, which yaegi compiles from sources it reads itself, is left as is.
+const (
+	yieldPackage = "booker/yield"
+	yieldName    = "booker_yield"
+	yieldCounter = "booker_yields"
+	// yieldInterval is the number of statements counted for one to yield
+	yieldInterval = 64
+	// jsYieldInterval is how long yield lets code run before handing
+	// control back to the JS event loop
+	jsYieldInterval = 10 * time.Millisecond
+)
+
+// Letting the other goroutines run is not enough for the JS event loop to:
+// the wasm runtime only returns to it once every goroutine is blocked. Until
+// then, no JS callback runs, neither that of a Promise's cancel method called
+// from a click handler or a timer, nor that of an AbortSignal. So yield
+// blocks, once jsYieldInterval has passed, until a JS task scheduled with
+// setTimeout has run, letting the tasks queued before it run too. A
+// MessageChannel would be faster, but Node.js runs its messages ahead of its
+// timers. Goroutines yielding meanwhile wait for the same task.
+var (
+	jsYieldMu sync.Mutex
+	// jsYielded is closed once the task scheduled runs, nil when none is
+	jsYielded   chan struct{}
+	lastJSYield = time.Now()
+)
+
+var jsYieldFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	jsYieldMu.Lock()
+	defer jsYieldMu.Unlock()
+	close(jsYielded)
+	jsYielded = nil
+	lastJSYield = time.Now()
+	return nil
+})
+
+// yield lets the other goroutines run, and the JS event loop too once
+// jsYieldInterval has passed since it last did.
+func yield() {
+	runtime.Gosched()
+	jsYieldMu.Lock()
+	if time.Since(lastJSYield) < jsYieldInterval {
+		jsYieldMu.Unlock()
+		return
+	}
+	if jsYielded == nil {
+		jsYielded = make(chan struct{})
+		js.Global().Call("setTimeout", jsYieldFunc, 0)
+	}
+	yielded := jsYielded
+	jsYieldMu.Unlock()
+	<-yielded
+}
+
+// useYield declares what the statements of addYields use, in the main
+// package of the interpreter.
+func (r *runner) useYield() {
+	r.use(interp.Exports{yieldPackage + "/yield": {"Yield": reflect.ValueOf(yield)}})
+	for _, decl := range []string{
+		"import " + yieldName + " " + strconv.Quote(yieldPackage),
+		"var " + yieldCounter + " int",
+	} {
+		if _, err := r.interp.Eval(decl); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// compileCode compiles code as Interpreter.Compile does, after addYields.
+// Code that fails to parse, or has build constraints, which yaegi evaluates
+// when parsing, is compiled as is, for yaegi to report or skip it. So is the
+// code of stepped runs, which already yield at every step, and whose steps
+// the inserted statements would count.
+func (r *runner) compileCode(code string) (*interp.Program, error) {
+	n := r.parseCode(code)
+	if n == nil || r.stepping() {
+		return r.interp.Compile(code)
+	}
+	if f, ok := n.(*ast.File); ok && f.Name.Name != "main" {
+		// Imports are scoped by file, and the counter by package, so only
+		// the code of the main package sees those of useYield
+		f.Decls = append(append([]ast.Decl{yieldImport()}, f.Decls...), yieldCounterDecl())
+	}
+	addYields(n)
+	return r.interp.CompileAST(n)
+}
+
+// yieldImport returns the declaration importing yield as yieldName.
+func yieldImport() *ast.GenDecl {
+	spec := &ast.ImportSpec{Name: ast.NewIdent(yieldName), Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(yieldPackage)}}
+	return &ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{spec}}
+}
+
+// yieldCounterDecl returns the declaration of yieldCounter.
+func yieldCounterDecl() *ast.GenDecl {
+	counter := &ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent(yieldCounter)}, Type: ast.NewIdent("int")}
+	return &ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{counter}}
+}
+
+// parseCode parses code as Interpreter.Compile does: as a file, after a
+// package clause for declarations of a fragment, or as the body of a main
+// function wrapping the statements of a fragment. It returns nil when
+// code has build constraints or fails to parse.
+func (r *runner) parseCode(code string) ast.Node {
+	if strings.Contains(code, "go:build") || strings.Contains(code, "+build") || strings.Contains(code, "yaegi:tags") {
+		return nil
+	}
+	parse := func(src string) *ast.File {
+		f, err := parser.ParseFile(r.interp.FileSet(), interp.DefaultSourceName, src, parser.DeclarationErrors|parser.ParseComments)
+		if err != nil {
+			return nil
+		}
+		return f
+	}
+	wrapInMain := func() ast.Node {
+		if f := parse("package main; func main() {" + code + "\n}"); f != nil {
+			return f.Decls[0].(*ast.FuncDecl).Body
+		}
+		return nil
+	}
+
+	var s scanner.Scanner
+	s.Init(token.NewFileSet().AddFile("", -1, len(code)), []byte(code), nil, 0)
+	switch _, tok, _ := s.Scan(); tok {
+	case token.PACKAGE:
+		if f := parse(code); f != nil {
+			return f
+		}
+	case token.CONST, token.FUNC, token.IMPORT, token.TYPE, token.VAR:
+		if f := parse("package main;" + code); f != nil {
+			return f
+		}
+		// As yaegi, retry function literals as statements
+		if tok == token.FUNC {
+			return wrapInMain()
+		}
+	default:
+		return wrapInMain()
+	}
+	return nil
+}
+
+// addYields inserts at the start of the bodies of the loops and functions of
+// n the statement
+//
+//	if booker_yields--; booker_yields < 0 {
+//		booker_yields = yieldInterval
+//		booker_yield.Yield()
+//	}
+func addYields(n ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		var body *ast.BlockStmt
+		switch n := n.(type) {
+		case *ast.ForStmt:
+			body = n.Body
+		case *ast.RangeStmt:
+			body = n.Body
+		case *ast.FuncDecl:
+			body = n.Body
+		case *ast.FuncLit:
+			body = n.Body
+		}
+		if body != nil {
+			body.List = append([]ast.Stmt{yieldStmt(firstLeaf(body))}, body.List...)
+		}
+		return true
+	})
+}
+
+// firstLeaf returns the position of the first identifier or function literal
+// the statements of body evaluate, the right-hand side of assignments first,
+// or that of its opening brace if there are none but empty ones. yaegi
+// reports panics at the first node their function executes, which addYields
+// inserts, so that is given about the position yaegi would have reported.
+func firstLeaf(body *ast.BlockStmt) token.Pos {
+	var pos token.Pos
+	var visit func(n ast.Node) bool
+	inspect := func(exprs ...[]ast.Expr) {
+		for _, list := range exprs {
+			for _, e := range list {
+				ast.Inspect(e, visit)
+			}
+		}
+	}
+	visit = func(n ast.Node) bool {
+		if pos.IsValid() {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.Ident, *ast.FuncLit:
+			pos = n.Pos()
+		case *ast.AssignStmt:
+			inspect(n.Rhs, n.Lhs)
+		case *ast.ValueSpec:
+			names := make([]ast.Expr, len(n.Names))
+			for i, name := range n.Names {
+				names[i] = name
+			}
+			inspect(n.Values, names)
+		default:
+			return true
+		}
+		return false
+	}
+	for _, stmt := range body.List {
+		if _, ok := stmt.(*ast.EmptyStmt); ok {
+			continue
+		}
+		ast.Inspect(stmt, visit)
+		if !pos.IsValid() {
+			return stmt.Pos()
+		}
+		return pos
+	}
+	return body.Lbrace
+}
+
+// yieldStmt returns the statement of addYields, at pos.
+func yieldStmt(pos token.Pos) ast.Stmt {
+	counter := func() ast.Expr { return &ast.Ident{NamePos: pos, Name: yieldCounter} }
+	lit := func(n int) ast.Expr { return &ast.BasicLit{ValuePos: pos, Kind: token.INT, Value: strconv.Itoa(n)} }
+	call := &ast.CallExpr{
+		Fun:    &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: yieldName}, Sel: &ast.Ident{NamePos: pos, Name: "Yield"}},
+		Lparen: pos,
+		Rparen: pos,
+	}
+	reset := &ast.AssignStmt{Lhs: []ast.Expr{counter()}, TokPos: pos, Tok: token.ASSIGN, Rhs: []ast.Expr{lit(yieldInterval)}}
+	return &ast.IfStmt{
+		If:   pos,
+		Init: &ast.IncDecStmt{X: counter(), TokPos: pos, Tok: token.DEC},
+		Cond: &ast.BinaryExpr{X: counter(), OpPos: pos, Op: token.LSS, Y: lit(0)},
+		Body: &ast.BlockStmt{Lbrace: pos, List: []ast.Stmt{reset, &ast.ExprStmt{X: call}}, Rbrace: pos},
+	}
+}